@@ -0,0 +1,430 @@
+package audiocore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+// ========================= Recepción de audio =========================
+
+// oggFormat devuelve sample rate y canales para el header OGG según el codec
+// negociado. Opus siempre se anuncia como opus/48000/2 (RFC 7587): el mono
+// viene en el fmtp (ver opusMono) y entonces el OpusHead lleva 1 canal. Si
+// falta algún dato se usa 48000/2.
+func oggFormat(codec webrtc.RTPCodecParameters) (rate uint32, channels uint16) {
+	rate, channels = codec.ClockRate, codec.Channels
+	if rate == 0 {
+		rate = 48000
+	}
+	if channels == 0 {
+		channels = 2
+	}
+	if strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) && opusMono(codec.SDPFmtpLine) {
+		channels = 1
+	}
+	return rate, channels
+}
+
+// opusMono indica si el fmtp de Opus pide mono: stereo=0 o sprop-stereo=0,
+// sin que ninguno de los dos esté en 1
+func opusMono(fmtp string) bool {
+	mono := false
+	for _, kv := range strings.Split(fmtp, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		if k != "stereo" && k != "sprop-stereo" {
+			continue
+		}
+		if v == "1" {
+			return false
+		}
+		mono = mono || v == "0"
+	}
+	return mono
+}
+
+// audioRecorder es el destino de la grabación de audio entrante
+type audioRecorder interface {
+	WriteRTP(pkt *rtp.Packet) error
+	close()
+}
+
+// oggSegments escribe el audio entrante en uno o más OGG. Al superar
+// Config.RecordingMaxDuration o RecordingMaxBytes cierra el archivo y abre el
+// siguiente (audio-<id>-002.ogg, ...). Se rota siempre entre paquetes RTP,
+// así que cada segmento tiene sus propios headers y se puede reproducir
+// solo.
+type oggSegments struct {
+	call     *Call
+	track    *webrtc.TrackRemote
+	maxAge   time.Duration
+	maxBytes int64
+
+	n        int // número del segmento actual (1 = sin sufijo)
+	ogg      *oggwriter.OggWriter
+	meta     *RecordingMeta
+	openedAt time.Time
+	written  int64
+}
+
+func newOGGSegments(call *Call, track *webrtc.TrackRemote) *oggSegments {
+	return &oggSegments{
+		call:     call,
+		track:    track,
+		maxAge:   call.m.cfg.RecordingMaxDuration,
+		maxBytes: call.m.cfg.RecordingMaxBytes,
+	}
+}
+
+func (s *oggSegments) path() string {
+	if s.n == 1 {
+		return s.call.m.recordingPath(fmt.Sprintf("audio-%s.ogg", s.call.ID))
+	}
+	return s.call.m.recordingPath(fmt.Sprintf("audio-%s-%03d.ogg", s.call.ID, s.n))
+}
+
+// open abre el siguiente segmento y su sidecar
+func (s *oggSegments) open() error {
+	s.n++
+	abs := s.path()
+	rate, channels := oggFormat(s.track.Codec())
+	ogg, err := oggwriter.New(abs, rate, channels)
+	if err != nil {
+		return err
+	}
+	s.ogg, s.openedAt, s.written = ogg, time.Now(), 0
+
+	s.meta = newRecordingMeta(s.call, s.track, abs)
+	if err := s.meta.write(); err != nil {
+		s.call.log.Error("Error escribiendo metadata de grabación", "err", err)
+	}
+	s.call.log.Info("Grabando audio", "path", abs, "segment", s.n)
+	return nil
+}
+
+// close cierra el segmento actual y completa su sidecar
+func (s *oggSegments) close() {
+	if s.ogg == nil {
+		return
+	}
+	if err := s.ogg.Close(); err != nil {
+		s.call.log.Error("Error cerrando ogg", "err", err)
+	}
+	s.call.recordingDone(s.meta)
+	s.ogg = nil
+}
+
+// full indica si el segmento actual llegó a alguno de los topes
+func (s *oggSegments) full() bool {
+	return (s.maxAge > 0 && time.Since(s.openedAt) >= s.maxAge) ||
+		(s.maxBytes > 0 && s.written >= s.maxBytes)
+}
+
+// WriteRTP escribe el paquete, rotando antes si hace falta
+func (s *oggSegments) WriteRTP(pkt *rtp.Packet) error {
+	if s.full() {
+		s.close()
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if err := s.ogg.WriteRTP(pkt); err != nil {
+		return err
+	}
+	s.written += int64(len(pkt.Payload))
+	return nil
+}
+
+// SetupAudioReceiver graba el audio entrante de track: Opus en OGG y G.711
+// en WAV, en Config.RecordingDir. Si la llamada tiene IdleTimeout, cuelga
+// cuando pasa ese tiempo sin recibir RTP. Bloquea hasta que termina el
+// track; CreateCall lo llama desde OnTrack.
+func (m *Manager) SetupAudioReceiver(call *Call, track *webrtc.TrackRemote) {
+	call.log.Info("Audio entrante detectado", "codec", track.Codec().MimeType)
+
+	if err := m.EnsureRecordingDir(); err != nil {
+		call.log.Error("Error preparando grabación", "err", err)
+		return
+	}
+	// Opus va a OGG (con rotación); G.711 se decodifica a WAV
+	var rec audioRecorder
+	if isG711(track.Codec().MimeType) {
+		wav, err := newWAVRecorder(call, track)
+		if err != nil {
+			call.log.Error("Error creando wav", "err", err)
+			return
+		}
+		rec = wav
+	} else {
+		ogg := newOGGSegments(call, track)
+		if err := ogg.open(); err != nil {
+			call.log.Error("Error creando ogg", "err", err)
+			return
+		}
+		rec = ogg
+	}
+	defer rec.close()
+
+	// Colgar por inactividad, si está habilitado
+	idle := call.IdleTimeout
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.NewTimer(idle)
+		defer timer.Stop()
+		go func() {
+			select {
+			case <-timer.C:
+				call.log.Info("Sin RTP, colgando", "idle", idle)
+				m.CloseCall(call)
+			case <-call.Done:
+				// colgada por otro lado (hangup, fallo): no dejar la goroutine viva
+			}
+		}()
+	}
+
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			call.log.Info("Fin de track", "err", err)
+			return
+		}
+		call.markRTP(pkt.MarshalSize())
+		if call.capture != nil {
+			if raw, err := pkt.Marshal(); err == nil {
+				if err := call.capture.writeIncoming(raw); err != nil {
+					call.log.Error("Error escribiendo captura RTP", "err", err)
+				}
+			}
+		}
+		if timer != nil {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(idle)
+		}
+
+		call.log.Debug("RTP recibido", "ssrc", pkt.SSRC, "seq", pkt.SequenceNumber, "ts", pkt.Timestamp)
+		if writeErr := rec.WriteRTP(pkt); writeErr != nil {
+			call.log.Error("Error escribiendo grabación", "err", writeErr)
+			return
+		}
+	}
+}
+
+// ========================= Emisión de audio =========================
+
+// Duración de frame asumida para el pacing cuando el paquete Opus no se
+// puede interpretar (ver opusPacketDuration); también es el ritmo del
+// comfort noise
+const AudioFrameTime = 20 * time.Millisecond
+
+// Duración de frame Opus por cada config del TOC (RFC 6716, 3.1), en
+// microsegundos para cubrir los 2.5ms de CELT
+var opusConfigDuration = [32]time.Duration{
+	// SILK NB/MB/WB: 10, 20, 40, 60 ms
+	10000, 20000, 40000, 60000, 10000, 20000, 40000, 60000, 10000, 20000, 40000, 60000,
+	// Hybrid SWB/FB: 10, 20 ms
+	10000, 20000, 10000, 20000,
+	// CELT NB/WB/SWB/FB: 2.5, 5, 10, 20 ms
+	2500, 5000, 10000, 20000, 2500, 5000, 10000, 20000,
+	2500, 5000, 10000, 20000, 2500, 5000, 10000, 20000,
+}
+
+// opusPacketDuration calcula la duración de un paquete Opus a partir del
+// byte TOC (y de la cantidad de frames si es code 3). Si no se puede
+// interpretar devuelve AudioFrameTime.
+func opusPacketDuration(pkt []byte) time.Duration {
+	if len(pkt) == 0 || isOpusHeader(pkt) {
+		return AudioFrameTime
+	}
+	toc := pkt[0]
+	frame := opusConfigDuration[toc>>3] * time.Microsecond
+
+	frames := 1
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(pkt) < 2 {
+			return AudioFrameTime
+		}
+		frames = int(pkt[1] & 0x3F)
+	}
+	d := time.Duration(frames) * frame
+	// Un paquete Opus dura como máximo 120ms
+	if d <= 0 || d > 120*time.Millisecond {
+		return AudioFrameTime
+	}
+	return d
+}
+
+// isOpusHeader indica si el paquete es OpusHead u OpusTags: metadatos del
+// stream que no se pueden mandar como audio
+func isOpusHeader(pkt []byte) bool {
+	return bytes.HasPrefix(pkt, []byte("OpusHead")) || bytes.HasPrefix(pkt, []byte("OpusTags"))
+}
+
+// sampleWriter es lo que usa la emisión de la pista local
+// (TrackLocalStaticSample)
+type sampleWriter interface {
+	WriteSample(media.Sample) error
+}
+
+// Frame Opus de silencio (CELT FB 20ms, el mismo que usan los navegadores
+// con DTX)
+var opusSilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+// sendComfortNoise escribe silencio Opus cada AudioFrameTime mientras no haya
+// emisión activa (o esté en pausa) y se detiene al cerrarse la llamada.
+// Cuando sendOGGAudio empieza a emitir, deja de escribir solo.
+func sendComfortNoise(call *Call, track sampleWriter) {
+	if !call.comfortNoise.CompareAndSwap(false, true) {
+		return // ya hay uno corriendo (p.ej. connected tras un ICE restart)
+	}
+	defer call.comfortNoise.Store(false)
+
+	ticker := time.NewTicker(AudioFrameTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-call.Done:
+			return
+		case <-ticker.C:
+		}
+		if call.playbackActive.Load() && !call.playbackPaused.Load() {
+			continue
+		}
+		if err := track.WriteSample(media.Sample{
+			Data:     opusSilenceFrame,
+			Duration: AudioFrameTime,
+		}); err != nil {
+			call.log.Error("Comfort noise: WriteSample falló", "err", err)
+			return
+		}
+	}
+}
+
+// sendOGGAudio empuja los paquetes Opus de oggPath a track, uno por sample y
+// con el pacing de su TOC (ver opusPacketDuration). Repite el archivo según
+// Config.OutLoop y corta al vencer Config.OutTimeout o cuando se cierra la
+// llamada. Corre una sola vez por llamada: un connected posterior (ICE
+// restart) no vuelve a empezar el archivo.
+func (m *Manager) sendOGGAudio(call *Call, track sampleWriter, oggPath string) {
+	if !call.oggStarted.CompareAndSwap(false, true) {
+		return
+	}
+
+	f, err := os.Open(oggPath)
+	if err != nil {
+		call.log.Error("No se pudo abrir el OGG", "err", err)
+		return
+	}
+	defer f.Close()
+
+	r := newOGGPacketReader(f)
+
+	// timeout opcional (cubre todas las repeticiones)
+	var timeout <-chan time.Time
+	if m.cfg.OutTimeout > 0 {
+		t := time.NewTimer(m.cfg.OutTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	onTimeout := func() {
+		call.log.Info("OUTGOING: timeout alcanzado", "timeout", m.cfg.OutTimeout)
+		if m.cfg.CloseOnTimeout {
+			m.CloseCall(call)
+		}
+	}
+
+	// Habilita PausePlayback/ResumePlayback mientras dure el envío
+	call.playbackActive.Store(true)
+	defer func() {
+		call.playbackActive.Store(false)
+		call.playbackPaused.Store(false)
+	}()
+
+	loops := m.cfg.OutLoop
+
+	for played := 1; ; {
+		select {
+		case <-timeout:
+			onTimeout()
+			return
+		case <-call.Done:
+			call.log.Info("OUTGOING: llamada cerrada, deteniendo envío")
+			return
+		case cmd := <-call.playback:
+			if cmd != playbackPause {
+				continue
+			}
+			// En pausa no se escriben samples; el drenado de RTCP sigue aparte
+			call.playbackPaused.Store(true)
+			call.log.Info("OUTGOING: envío en pausa")
+			for paused := true; paused; {
+				select {
+				case cmd := <-call.playback:
+					paused = cmd != playbackResume
+				case <-timeout:
+					onTimeout()
+					return
+				case <-call.Done:
+					return
+				}
+			}
+			call.playbackPaused.Store(false)
+			call.log.Info("OUTGOING: envío reanudado")
+		default:
+		}
+
+		// Lee el siguiente paquete Opus (una página puede traer varios)
+		pkt, err := r.nextPacket()
+		if err == io.EOF {
+			if loops >= 0 && played >= loops {
+				call.log.Info("OUTGOING: EOF OGG", "path", oggPath)
+				return
+			}
+			// Volver al inicio sin esperar para que no haya hueco audible
+			played++
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				call.log.Error("OGG seek falló", "err", err)
+				return
+			}
+			r = newOGGPacketReader(f)
+			call.log.Info("OUTGOING: repitiendo OGG", "loop", played)
+			continue
+		}
+		if err != nil {
+			call.log.Error("Lectura del OGG falló", "err", err)
+			return
+		}
+		// OpusHead y OpusTags vuelven a aparecer en cada repetición
+		if isOpusHeader(pkt) {
+			continue
+		}
+
+		// Empuja el paquete hacia el remoto, con la duración de su TOC
+		frame := opusPacketDuration(pkt)
+		if werr := track.WriteSample(media.Sample{
+			Data:     pkt,
+			Duration: frame,
+		}); werr != nil {
+			call.log.Error("WriteSample falló", "err", werr)
+			return
+		}
+
+		time.Sleep(frame) // pacing simple
+	}
+}
@@ -0,0 +1,523 @@
+package audiocore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+// recordingTrack guarda los samples que escribiría la pista local
+type recordingTrack struct {
+	mu      sync.Mutex
+	samples []media.Sample
+}
+
+func (t *recordingTrack) WriteSample(s media.Sample) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, s)
+	return nil
+}
+
+func (t *recordingTrack) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.samples)
+}
+
+// oggCRC es el CRC-32 de Ogg (polinomio 0x04c11db7, sin reflejar)
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, v := range b {
+		crc ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// oggPage arma una página Ogg con los paquetes dados (cada uno < 255 bytes)
+func oggPage(headerType byte, granule uint64, seq uint32, packets ...[]byte) []byte {
+	var payload []byte
+	var segments []byte
+	for _, p := range packets {
+		segments = append(segments, byte(len(p)))
+		payload = append(payload, p...)
+	}
+	return oggRawPage(headerType, granule, seq, segments, payload)
+}
+
+// oggRawPage arma una página Ogg con la tabla de segmentos tal cual, para
+// paquetes que siguen en otra página
+func oggRawPage(headerType byte, granule uint64, seq uint32, segments, payload []byte) []byte {
+	h := make([]byte, 27, 27+len(segments)+len(payload))
+	copy(h, "OggS")
+	h[5] = headerType
+	binary.LittleEndian.PutUint64(h[6:], granule)
+	binary.LittleEndian.PutUint32(h[14:], 1) // serial
+	binary.LittleEndian.PutUint32(h[18:], seq)
+	h[26] = byte(len(segments))
+	page := append(append(h, segments...), payload...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+	return page
+}
+
+// writeTestOGG escribe un OGG Opus con OpusHead, OpusTags y una página por
+// cada elemento de pages (granule acumulado según la duración de cada paquete)
+func writeTestOGG(t *testing.T, pages ...[][]byte) string {
+	t.Helper()
+	var raw [][]byte
+	var granule uint64
+	for i, packets := range pages {
+		for _, p := range packets {
+			granule += uint64(opusPacketDuration(p) * 48000 / time.Second)
+		}
+		raw = append(raw, oggPage(0x00, granule, uint32(i+2), packets...))
+	}
+	return writeTestOGGPages(t, raw...)
+}
+
+// writeTestOGGPages escribe OpusHead, OpusTags y las páginas ya armadas
+// (números de secuencia desde 2)
+func writeTestOGGPages(t *testing.T, pages ...[]byte) string {
+	t.Helper()
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8], head[9] = 1, 2                         // versión, canales
+	binary.LittleEndian.PutUint32(head[12:], 48000) // sample rate
+
+	var buf bytes.Buffer
+	buf.Write(oggPage(0x02, 0, 0, head))
+	buf.Write(oggPage(0x00, 0, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")))
+	for _, page := range pages {
+		buf.Write(page)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.ogg")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readOGGPackets devuelve los paquetes de audio de path, sin OpusHead ni
+// OpusTags
+func readOGGPackets(t *testing.T, path string) [][]byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var packets [][]byte
+	r := newOGGPacketReader(f)
+	for {
+		pkt, err := r.nextPacket()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isOpusHeader(pkt) {
+			packets = append(packets, pkt)
+		}
+	}
+}
+
+// Con OutLoop 2 se emite el doble de audio y nunca OpusHead/OpusTags
+func TestSendOGGAudioLoop(t *testing.T) {
+	m, _ := newTestManager(t, Config{OutLoop: 2})
+	frame := []byte{0x20, 0xAA} // SILK MB 10ms, code 0
+	path := writeTestOGG(t, [][]byte{frame}, [][]byte{frame}, [][]byte{frame})
+
+	track := &recordingTrack{}
+	m.sendOGGAudio(testCall(m, "loop"), track, path)
+
+	if len(track.samples) != 6 {
+		t.Fatalf("samples = %d, want 6 (3 páginas x 2 vueltas)", len(track.samples))
+	}
+	for i, s := range track.samples {
+		if isOpusHeader(s.Data) {
+			t.Errorf("sample %d es una cabecera Ogg: %q", i, s.Data)
+		}
+		if !bytes.Equal(s.Data, frame) {
+			t.Errorf("sample %d = %x, want %x", i, s.Data, frame)
+		}
+	}
+}
+
+// Un segundo connected (ICE restart) no debe lanzar otra emisión ni apagar
+// el flag de la que está en curso
+func TestSendOGGAudioRunsOnce(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	call := testCall(m, "once")
+	call.oggStarted.Store(true)
+	call.playbackActive.Store(true)
+
+	m.sendOGGAudio(call, nil, "/no/existe.ogg")
+
+	if !call.playbackActive.Load() {
+		t.Fatal("la segunda llamada a sendOGGAudio apagó playbackActive")
+	}
+}
+
+// opusTOC arma el byte TOC de config (0-31) y code (0-3)
+func opusTOC(config, code byte) byte { return config<<3 | code }
+
+func TestOpusPacketDuration(t *testing.T) {
+	ms := time.Millisecond
+	cases := []struct {
+		name string
+		pkt  []byte
+		want time.Duration
+	}{
+		{"SILK NB 10ms", []byte{opusTOC(0, 0), 0}, 10 * ms},
+		{"SILK WB 20ms", []byte{opusTOC(9, 0), 0}, 20 * ms},
+		{"SILK MB 40ms", []byte{opusTOC(6, 0), 0}, 40 * ms},
+		{"SILK NB 60ms", []byte{opusTOC(3, 0), 0}, 60 * ms},
+		{"Hybrid FB 10ms", []byte{opusTOC(14, 0), 0}, 10 * ms},
+		{"CELT NB 2.5ms", []byte{opusTOC(16, 0), 0}, 2500 * time.Microsecond},
+		{"CELT FB 20ms", []byte{opusTOC(31, 0), 0}, 20 * ms},
+		{"code 1: dos frames iguales", []byte{opusTOC(31, 1), 0}, 40 * ms},
+		{"code 2: dos frames distintos", []byte{opusTOC(1, 2), 0}, 40 * ms},
+		{"code 3: 3 frames", []byte{opusTOC(0, 3), 3}, 30 * ms},
+		{"code 3 sin count", []byte{opusTOC(0, 3)}, AudioFrameTime},
+		{"code 3 con más de 120ms", []byte{opusTOC(3, 3), 3}, AudioFrameTime},
+		{"code 3 con 0 frames", []byte{opusTOC(0, 3), 0}, AudioFrameTime},
+		{"vacío", nil, AudioFrameTime},
+		{"OpusTags", []byte("OpusTags...."), AudioFrameTime},
+		{"silencio de comfort noise", opusSilenceFrame, 20 * ms},
+	}
+	for _, tc := range cases {
+		if got := opusPacketDuration(tc.pkt); got != tc.want {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Una página con dos paquetes se emite como dos samples, cada uno con la
+// duración de su TOC
+func TestSendOGGAudioTwoPacketPage(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	p10 := []byte{opusTOC(0, 0), 1}  // SILK NB 10ms
+	p20 := []byte{opusTOC(31, 0), 2} // CELT FB 20ms
+	path := writeTestOGG(t, [][]byte{p10, p20})
+
+	track := &recordingTrack{}
+	m.sendOGGAudio(testCall(m, "two"), track, path)
+
+	want := []media.Sample{
+		{Data: p10, Duration: 10 * time.Millisecond},
+		{Data: p20, Duration: 20 * time.Millisecond},
+	}
+	if len(track.samples) != len(want) {
+		t.Fatalf("samples = %d, want %d", len(track.samples), len(want))
+	}
+	for i, s := range track.samples {
+		if !bytes.Equal(s.Data, want[i].Data) || s.Duration != want[i].Duration {
+			t.Errorf("sample %d = %x/%v, want %x/%v", i, s.Data, s.Duration, want[i].Data, want[i].Duration)
+		}
+	}
+}
+
+// Páginas con frames de 10/20/40/60ms y páginas con varios paquetes (como
+// las de opusenc): cada paquete sale suelto y la duración acumulada tiene
+// que ser la del audio
+func TestOGGPacketReaderMixedFrames(t *testing.T) {
+	p10 := []byte{opusTOC(0, 0), 1}
+	p20 := []byte{opusTOC(31, 0), 2}
+	p40 := []byte{opusTOC(2, 0), 3}
+	p60 := []byte{opusTOC(3, 0), 4}
+	many := make([][]byte, 50) // 50 x 20ms = 1s en una sola página
+	for i := range many {
+		many[i] = p20
+	}
+
+	packets := readOGGPackets(t, writeTestOGG(t,
+		[][]byte{p10},
+		[][]byte{p20},
+		[][]byte{p40},
+		[][]byte{p60},
+		many,
+		[][]byte{p60, p40, p10},
+	))
+
+	if len(packets) != 57 {
+		t.Fatalf("paquetes = %d, want 57", len(packets))
+	}
+	var total time.Duration
+	for _, pkt := range packets {
+		total += opusPacketDuration(pkt)
+	}
+	if total != 1240*time.Millisecond {
+		t.Errorf("total = %v, want 1.24s", total)
+	}
+}
+
+// Un paquete que sigue en la página siguiente (la primera con granule -1)
+// se arma entero y se emite con la duración de su TOC
+func TestSendOGGAudioPacketAcrossPages(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	long := make([]byte, 300)
+	long[0] = opusTOC(2, 0) // SILK MB 40ms
+	for i := 1; i < len(long); i++ {
+		long[i] = byte(i)
+	}
+	p20 := []byte{opusTOC(31, 0), 2}
+
+	path := writeTestOGGPages(t,
+		oggRawPage(0x00, ^uint64(0), 2, []byte{255}, long[:255]),
+		oggRawPage(0x01, 3*960, 3, []byte{45, 2}, append(append([]byte{}, long[255:]...), p20...)),
+	)
+
+	track := &recordingTrack{}
+	m.sendOGGAudio(testCall(m, "span"), track, path)
+
+	if len(track.samples) != 2 {
+		t.Fatalf("samples = %d, want 2", len(track.samples))
+	}
+	if s := track.samples[0]; !bytes.Equal(s.Data, long) || s.Duration != 40*time.Millisecond {
+		t.Errorf("paquete partido = %d bytes/%v, want 300 bytes/40ms", len(s.Data), s.Duration)
+	}
+	if s := track.samples[1]; !bytes.Equal(s.Data, p20) || s.Duration != 20*time.Millisecond {
+		t.Errorf("segundo paquete = %x/%v, want %x/20ms", s.Data, s.Duration, p20)
+	}
+}
+
+// En pausa la emisión no escribe samples y al reanudar sigue escribiendo
+func TestSendOGGAudioPauseResume(t *testing.T) {
+	m, _ := newTestManager(t, Config{OutLoop: -1})
+	path := writeTestOGG(t, [][]byte{{opusTOC(0, 0), 1}}) // 10ms, en loop
+	call := testCall(m, "pause")
+	track := &recordingTrack{}
+
+	done := make(chan struct{})
+	go func() {
+		m.sendOGGAudio(call, track, path)
+		close(done)
+	}()
+	defer func() {
+		close(call.Done)
+		<-done
+	}()
+
+	// waitSamples espera a que haya más de n samples escritos
+	waitSamples := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for track.count() <= n {
+			if time.Now().After(deadline) {
+				t.Fatalf("la emisión no pasó de %d samples", n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitSamples(0)
+
+	if err := call.PausePlayback(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !call.playbackPaused.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("la emisión no entró en pausa")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if state := call.playbackState(); state != "paused" {
+		t.Errorf("playbackState() = %q, want paused", state)
+	}
+	paused := track.count()
+	time.Sleep(10 * AudioFrameTime)
+	if n := track.count(); n != paused {
+		t.Fatalf("en pausa se escribieron %d samples", n-paused)
+	}
+
+	if err := call.ResumePlayback(); err != nil {
+		t.Fatal(err)
+	}
+	waitSamples(paused)
+}
+
+// Sin emisión de OGG, sendComfortNoise escribe silencio hasta que se cuelga
+func TestSendComfortNoiseWithoutOGG(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	call := testCall(m, "cn")
+	track := &recordingTrack{}
+
+	done := make(chan struct{})
+	go func() {
+		sendComfortNoise(call, track)
+		close(done)
+	}()
+	time.Sleep(5 * AudioFrameTime)
+	close(call.Done)
+	<-done
+
+	track.mu.Lock()
+	defer track.mu.Unlock()
+	if len(track.samples) < 2 {
+		t.Fatalf("samples = %d, want silencio continuo", len(track.samples))
+	}
+	for i, s := range track.samples {
+		if !bytes.Equal(s.Data, opusSilenceFrame) || s.Duration != AudioFrameTime {
+			t.Fatalf("sample %d = %x/%v, want silencio de %v", i, s.Data, s.Duration, AudioFrameTime)
+		}
+	}
+	if call.comfortNoise.Load() {
+		t.Error("comfortNoise debería quedar en false al terminar")
+	}
+}
+
+// El OpusHead de la grabación lleva 1 canal cuando el fmtp negociado es mono
+func TestOGGFormatHeader(t *testing.T) {
+	opus := func(fmtp string) webrtc.RTPCodecParameters {
+		return webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: fmtp,
+		}}
+	}
+	cases := []struct {
+		name     string
+		codec    webrtc.RTPCodecParameters
+		channels uint16
+	}{
+		{"sin codec", webrtc.RTPCodecParameters{}, 2},
+		{"fmtp de pion", opus("minptime=10;useinbandfec=1"), 2},
+		{"stereo=0", opus("minptime=10;stereo=0;useinbandfec=1"), 1},
+		{"sprop-stereo=0", opus("sprop-stereo=0"), 1},
+		{"stereo=1", opus("stereo=1;sprop-stereo=1"), 2},
+		{"mono y estéreo", opus("stereo=0;sprop-stereo=1"), 2},
+	}
+	for _, tc := range cases {
+		rate, channels := oggFormat(tc.codec)
+		if rate != 48000 || channels != tc.channels {
+			t.Errorf("%s: oggFormat = %d/%d, want 48000/%d", tc.name, rate, channels, tc.channels)
+			continue
+		}
+
+		var buf bytes.Buffer
+		w, err := oggwriter.NewWith(&buf, rate, channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = w.Close()
+		head := buf.Bytes()
+		i := bytes.Index(head, []byte("OpusHead"))
+		if i < 0 || len(head) < i+19 {
+			t.Fatalf("%s: sin OpusHead", tc.name)
+		}
+		if got := head[i+9]; got != byte(tc.channels) {
+			t.Errorf("%s: OpusHead canales = %d, want %d", tc.name, got, tc.channels)
+		}
+		if got := binary.LittleEndian.Uint32(head[i+12:]); got != 48000 {
+			t.Errorf("%s: OpusHead sample rate = %d", tc.name, got)
+		}
+	}
+}
+
+// Con un tope chico de bytes la grabación se parte en varios segmentos,
+// cada uno con sus headers Opus y su sidecar
+func TestOGGSegmentsRotation(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var done []string
+	m, _ := newTestManager(t, Config{
+		RecordingDir:      dir,
+		RecordingMaxBytes: 10,
+		OnRecording: func(_ *Call, rec *RecordingMeta) {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, filepath.Base(rec.Path()))
+		},
+	})
+
+	call := testCall(m, "100-1")
+	segs := newOGGSegments(call, &webrtc.TrackRemote{})
+	if err := segs.open(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		pkt := &rtp.Packet{
+			Header:  rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i * 960)},
+			Payload: []byte{opusTOC(31, 0), 1, 2, byte(i)}, // 4 bytes
+		}
+		if err := segs.WriteRTP(pkt); err != nil {
+			t.Fatalf("paquete %d: %v", i, err)
+		}
+	}
+	segs.close()
+
+	// 3 paquetes (12 bytes) por segmento: 3 + 3 + 2
+	wantPackets := map[string]int{
+		"audio-100-1.ogg":     3,
+		"audio-100-1-002.ogg": 3,
+		"audio-100-1-003.ogg": 2,
+	}
+	for name, want := range wantPackets {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("falta el segmento %s: %v", name, err)
+		}
+		r, hdr, err := oggreader.NewWith(f)
+		if err != nil {
+			f.Close()
+			t.Fatalf("%s no es un OGG Opus válido: %v", name, err)
+		}
+		if hdr.SampleRate != 48000 || hdr.Channels != 2 {
+			t.Errorf("%s: header %d Hz / %d canales", name, hdr.SampleRate, hdr.Channels)
+		}
+		got := 0
+		for {
+			page, _, err := r.ParseNextPage()
+			if err != nil {
+				break
+			}
+			if !isOpusHeader(page) && len(page) > 0 {
+				got++
+			}
+		}
+		f.Close()
+		if got != want {
+			t.Errorf("%s: %d paquetes, want %d", name, got, want)
+		}
+
+		var meta RecordingMeta
+		b, err := os.ReadFile(filepath.Join(dir, name[:len(name)-len(".ogg")]+".json"))
+		if err == nil {
+			err = json.Unmarshal(b, &meta)
+		}
+		if err != nil {
+			t.Errorf("%s: sidecar: %v", name, err)
+		} else if meta.File != name || meta.CallID != "100-1" || meta.EndedAt == nil {
+			t.Errorf("%s: sidecar = %+v", name, meta)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("audio-100-1-%03d.ogg", 4))); err == nil {
+		t.Error("se abrió un cuarto segmento de más")
+	}
+	// OnRecording recibe cada segmento al cerrarlo, en orden
+	want := []string{"audio-100-1.ogg", "audio-100-1-002.ogg", "audio-100-1-003.ogg"}
+	if fmt.Sprint(done) != fmt.Sprint(want) {
+		t.Errorf("OnRecording = %v, want %v", done, want)
+	}
+}
@@ -0,0 +1,212 @@
+package audiocore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Llamadas =========================
+
+// Eventos del ciclo de vida de una llamada (ver Config.OnEvent)
+const (
+	EventCallStarted = "call_started" // answer enviada al cliente (ver MarkStarted)
+	EventCallEnded   = "call_ended"   // llamada cerrada (por cualquier motivo)
+	EventCallFailed  = "call_failed"  // la conexión pasó a failed
+
+	EventCallMaxDuration = "call_max_duration" // colgada por Config.MaxCallDuration
+)
+
+// Si no llega RTP en esta ventana consideramos que el audio no fluye
+const audioFlowingWindow = 2 * time.Second
+
+// Call es una llamada creada por Manager.CreateCall
+type Call struct {
+	ID          string
+	PC          *webrtc.PeerConnection
+	Done        chan struct{}
+	IdleTimeout time.Duration // autocolgado sin RTP (0 = deshabilitado)
+	capture     *rtpCapture   // captura RTP a .pcap (nil = deshabilitada)
+	StartedAt   time.Time
+	RemoteAddr  string // quién pidió la llamada (ver CallOptions)
+
+	BytesReceived atomic.Int64 // RTP entrante acumulado
+	lastRTPAt     atomic.Int64 // UnixNano del último RTP
+
+	// Control de la emisión de audio en curso (ver sendOGGAudio)
+	playback       chan playbackCmd
+	playbackActive atomic.Bool
+	playbackPaused atomic.Bool
+	comfortNoise   atomic.Bool // hay un sendComfortNoise corriendo
+	oggStarted     atomic.Bool // sendOGGAudio ya arrancó (se emite una sola vez)
+
+	maxDurationArmed atomic.Bool // ya corre el timer de Config.MaxCallDuration
+
+	// callNew, callAnnounced o callClosed: call_ended/call_failed solo se
+	// emiten si antes salió call_started (ver MarkStarted)
+	lifecycle atomic.Int32
+
+	// Candidatos ICE locales (para la answer y para trickle ICE)
+	mu              sync.Mutex
+	localCandidates []webrtc.ICECandidateInit
+	gatheringDone   bool
+
+	m         *Manager
+	log       *slog.Logger // logger con call_id
+	closeOnce sync.Once
+}
+
+// Estados de Call.lifecycle
+const (
+	callNew       int32 = iota
+	callAnnounced       // call_started emitido
+	callClosed
+)
+
+// Comandos para la emisión de audio en curso
+type playbackCmd int
+
+const (
+	playbackPause playbackCmd = iota + 1
+	playbackResume
+)
+
+var errNoPlayback = errors.New("no hay emisión de audio en curso")
+
+// CallInfo es una foto del estado de una llamada (ver Call.Info)
+type CallInfo struct {
+	ID              string    `json:"id"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSec     float64   `json:"duration_sec"`
+	ConnectionState string    `json:"connection_state"`
+	ICEState        string    `json:"ice_state"`
+	AudioFlowing    bool      `json:"audio_flowing"`
+	BytesReceived   int64     `json:"bytes_received"`
+	RemoteAddr      string    `json:"remote_addr"`
+	Playback        string    `json:"playback"` // idle, playing o paused
+}
+
+func newCallID() string {
+	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Intn(100000))
+}
+
+// MarkStarted emite call_started con data una vez que la answer llegó al
+// otro extremo. No hace nada si la llamada ya se cerró, así cada
+// call_started tiene su call_ended y viceversa.
+func (c *Call) MarkStarted(data any) {
+	if c.lifecycle.CompareAndSwap(callNew, callAnnounced) {
+		c.m.emit(EventCallStarted, c.ID, data)
+	}
+}
+
+// Log devuelve el logger de la llamada (lleva call_id como atributo)
+func (c *Call) Log() *slog.Logger { return c.log }
+
+// armMaxDuration cuelga la llamada cuando pasan d desde la conexión
+// (d <= 0 = sin tope). Solo el primer llamado arma el timer; se cancela
+// cuando la llamada se cierra por otro motivo.
+func (c *Call) armMaxDuration(d time.Duration) {
+	if d <= 0 || !c.maxDurationArmed.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			c.log.Info("Duración máxima alcanzada, colgando", "max", d)
+			c.m.emit(EventCallMaxDuration, c.ID, c.Info())
+			c.m.CloseCall(c)
+		case <-c.Done:
+		}
+	}()
+}
+
+// markRTP registra un paquete RTP entrante de n bytes
+func (c *Call) markRTP(n int) {
+	c.BytesReceived.Add(int64(n))
+	c.lastRTPAt.Store(time.Now().UnixNano())
+}
+
+// Info toma una foto del estado actual de la llamada
+func (c *Call) Info() CallInfo {
+	last := c.lastRTPAt.Load()
+	return CallInfo{
+		ID:              c.ID,
+		StartedAt:       c.StartedAt,
+		DurationSec:     time.Since(c.StartedAt).Seconds(),
+		ConnectionState: c.PC.ConnectionState().String(),
+		ICEState:        c.PC.ICEConnectionState().String(),
+		AudioFlowing:    last > 0 && time.Since(time.Unix(0, last)) < audioFlowingWindow,
+		BytesReceived:   c.BytesReceived.Load(),
+		RemoteAddr:      c.RemoteAddr,
+		Playback:        c.playbackState(),
+	}
+}
+
+// PausePlayback suspende la emisión de audio sin soltar la pista
+func (c *Call) PausePlayback() error { return c.sendPlaybackCmd(playbackPause) }
+
+// ResumePlayback retoma la emisión desde donde quedó
+func (c *Call) ResumePlayback() error { return c.sendPlaybackCmd(playbackResume) }
+
+func (c *Call) sendPlaybackCmd(cmd playbackCmd) error {
+	if !c.playbackActive.Load() {
+		return errNoPlayback
+	}
+	select {
+	case c.playback <- cmd:
+		return nil
+	default:
+		return errors.New("hay comandos de emisión pendientes, reintente")
+	}
+}
+
+func (c *Call) playbackState() string {
+	switch {
+	case !c.playbackActive.Load():
+		return "idle"
+	case c.playbackPaused.Load():
+		return "paused"
+	}
+	return "playing"
+}
+
+// addLocalCandidate guarda un candidato local; nil marca el fin del gathering
+func (c *Call) addLocalCandidate(cand *webrtc.ICECandidate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cand == nil {
+		c.gatheringDone = true
+		return
+	}
+	c.localCandidates = append(c.localCandidates, cand.ToJSON())
+}
+
+// RestartGathering marca el inicio de un nuevo gathering (ICE restart) y
+// devuelve el índice desde el que llegarán sus candidatos
+func (c *Call) RestartGathering() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gatheringDone = false
+	return len(c.localCandidates)
+}
+
+// LocalCandidates devuelve los candidatos locales a partir del índice since
+// y si el gathering ya terminó
+func (c *Call) LocalCandidates(since int) ([]webrtc.ICECandidateInit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if since < 0 || since > len(c.localCandidates) {
+		since = len(c.localCandidates)
+	}
+	out := make([]webrtc.ICECandidateInit, len(c.localCandidates)-since)
+	copy(out, c.localCandidates[since:])
+	return out, c.gatheringDone
+}
@@ -0,0 +1,233 @@
+package audiocore
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// eventRecorder junta los eventos que emite el Manager (Config.OnEvent)
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) record(event, _ string, _ any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// waitNames espera a recibir al menos n eventos (el timer de duración
+// máxima los emite desde su propia goroutine) y devuelve los recibidos
+func (r *eventRecorder) waitNames(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := r.names()
+		if len(got) >= n || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newTestManager arma un Manager con cfg que junta sus eventos y, si cfg no
+// dice otra cosa, graba en un directorio temporal
+func newTestManager(t *testing.T, cfg Config) (*Manager, *eventRecorder) {
+	t.Helper()
+	rec := &eventRecorder{}
+	cfg.OnEvent = rec.record
+	if cfg.RecordingDir == "" {
+		cfg.RecordingDir = t.TempDir()
+	}
+	return NewManager(cfg), rec
+}
+
+// testCall arma una llamada de m sin PeerConnection ni registro
+func testCall(m *Manager, id string) *Call {
+	return &Call{
+		ID:       id,
+		Done:     make(chan struct{}),
+		playback: make(chan playbackCmd, 4),
+		m:        m,
+		log:      m.log.With("call_id", id),
+	}
+}
+
+// newTestCall registra en m una llamada con una PeerConnection sin negociar
+func newTestCall(t *testing.T, m *Manager) *Call {
+	t.Helper()
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := testCall(m, newCallID())
+	call.PC = pc
+	call.StartedAt = time.Now()
+	if !m.acquireSlot() {
+		t.Fatal("no hay lugar para la llamada de prueba")
+	}
+	m.calls.Store(call.ID, call)
+	return call
+}
+
+func TestCloseCallEventsArePaired(t *testing.T) {
+	cases := []struct {
+		name  string
+		run   func(m *Manager, c *Call)
+		wants []string
+	}{
+		{"falla antes de la answer", func(m *Manager, c *Call) {
+			m.CloseCall(c)
+		}, nil},
+		{"llamada anunciada", func(m *Manager, c *Call) {
+			c.MarkStarted(nil)
+			m.CloseCall(c)
+		}, []string{EventCallStarted, EventCallEnded}},
+		{"cerrada antes de anunciarse", func(m *Manager, c *Call) {
+			m.CloseCall(c)
+			c.MarkStarted(nil)
+		}, nil},
+		{"CloseCall repetido", func(m *Manager, c *Call) {
+			c.MarkStarted(nil)
+			m.CloseCall(c)
+			m.CloseCall(c)
+		}, []string{EventCallStarted, EventCallEnded}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, rec := newTestManager(t, Config{})
+			tc.run(m, newTestCall(t, m))
+
+			got := rec.names()
+			if len(got) != len(tc.wants) {
+				t.Fatalf("eventos = %v, want %v", got, tc.wants)
+			}
+			for i := range tc.wants {
+				if got[i] != tc.wants[i] {
+					t.Errorf("eventos = %v, want %v", got, tc.wants)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Más reservas simultáneas que el tope: entran exactamente MaxCalls
+func TestAcquireSlotConcurrent(t *testing.T) {
+	m, _ := newTestManager(t, Config{MaxCalls: 5})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if m.acquireSlot() {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if ok != 5 {
+		t.Fatalf("reservas exitosas = %d, want 5", ok)
+	}
+	for i := 0; i < ok; i++ {
+		m.releaseSlot()
+	}
+	if n := m.ActiveCalls(); n != 0 {
+		t.Fatalf("ActiveCalls = %d tras liberar, want 0", n)
+	}
+}
+
+// CloseCall libera el lugar una sola vez, aunque se llame varias veces
+func TestCloseCallReleasesSlot(t *testing.T) {
+	m, _ := newTestManager(t, Config{MaxCalls: 1})
+
+	call := newTestCall(t, m)
+	if m.acquireSlot() {
+		t.Fatal("con la llamada activa no debería haber lugar")
+	}
+	m.CloseCall(call)
+	m.CloseCall(call)
+	if n := m.ActiveCalls(); n != 0 {
+		t.Fatalf("ActiveCalls = %d, want 0", n)
+	}
+	if !m.acquireSlot() {
+		t.Fatal("tras colgar debería haber lugar")
+	}
+	m.releaseSlot()
+}
+
+// Con un tope chico la llamada se cuelga sola y avisa call_max_duration
+func TestArmMaxDurationAutoClose(t *testing.T) {
+	m, rec := newTestManager(t, Config{})
+	call := newTestCall(t, m)
+	call.MarkStarted(nil)
+
+	call.armMaxDuration(20 * time.Millisecond)
+	call.armMaxDuration(time.Hour) // el segundo connected no rearma
+
+	select {
+	case <-call.Done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("la llamada no se colgó al vencer la duración máxima")
+	}
+	if _, ok := m.Get(call.ID); ok {
+		t.Error("la llamada sigue en el registro")
+	}
+	got := rec.waitNames(t, 3)
+	want := []string{EventCallStarted, EventCallMaxDuration, EventCallEnded}
+	if len(got) != len(want) {
+		t.Fatalf("eventos = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("eventos = %v, want %v", got, want)
+		}
+	}
+}
+
+// Un hangup normal cancela el timer
+func TestArmMaxDurationCancelledOnHangup(t *testing.T) {
+	m, rec := newTestManager(t, Config{})
+	call := newTestCall(t, m)
+	call.MarkStarted(nil)
+
+	call.armMaxDuration(50 * time.Millisecond)
+	m.CloseCall(call)
+	time.Sleep(100 * time.Millisecond)
+
+	for _, e := range rec.names() {
+		if e == EventCallMaxDuration {
+			t.Fatal("se emitió call_max_duration para una llamada ya colgada")
+		}
+	}
+}
+
+func TestArmMaxDurationDisabled(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	call := newTestCall(t, m)
+	defer m.CloseCall(call)
+
+	call.armMaxDuration(0)
+	if call.maxDurationArmed.Load() {
+		t.Error("con 0 no debería armarse el timer")
+	}
+}
@@ -0,0 +1,126 @@
+package audiocore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ========================= Captura RTP (pcap) =========================
+
+// Direcciones ficticias (TEST-NET-1) para enmarcar el RTP en IPv4/UDP. En
+// Wireshark: Decode As... RTP sobre el puerto rtpCapturePort.
+var (
+	rtpCaptureRemoteIP = [4]byte{192, 0, 2, 1}
+	rtpCaptureLocalIP  = [4]byte{192, 0, 2, 2}
+)
+
+const rtpCapturePort = 5004
+
+// LINKTYPE_RAW: cada registro empieza directamente con el header IPv4
+const pcapLinkTypeRaw = 101
+
+// rtpCapture escribe paquetes RTP en un .pcap. Es seguro para uso
+// concurrente y se cierra con la llamada (ver Manager.CloseCall).
+type rtpCapture struct {
+	mu     sync.Mutex
+	f      *os.File
+	path   string
+	closed bool
+	ipID   uint16
+}
+
+// newRTPCapture crea rtp-<id>.pcap en el directorio de grabaciones
+func (m *Manager) newRTPCapture(callID string) (*rtpCapture, error) {
+	if err := m.EnsureRecordingDir(); err != nil {
+		return nil, err
+	}
+	path := m.recordingPath(fmt.Sprintf("rtp-%s.pcap", callID))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header global: magic, versión 2.4, zona/precisión 0, snaplen, linktype
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], pcapLinkTypeRaw)
+	if _, err := f.Write(hdr); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &rtpCapture{f: f, path: path}, nil
+}
+
+// writeIncoming registra un paquete RTP recibido (ya serializado)
+func (c *rtpCapture) writeIncoming(raw []byte) error {
+	return c.write(raw, rtpCaptureRemoteIP, rtpCaptureLocalIP)
+}
+
+func (c *rtpCapture) write(raw []byte, src, dst [4]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.ipID++
+	pkt := ipv4UDP(raw, src, dst, c.ipID)
+
+	now := time.Now()
+	rec := make([]byte, 16, 16+len(pkt))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(pkt)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(pkt)))
+	_, err := c.f.Write(append(rec, pkt...))
+	return err
+}
+
+// Close cierra el archivo; las escrituras posteriores se descartan
+func (c *rtpCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.f.Close()
+}
+
+// ipv4UDP envuelve payload en headers IPv4 + UDP (checksum UDP en 0, que
+// IPv4 permite)
+func ipv4UDP(payload []byte, src, dst [4]byte, id uint16) []byte {
+	total := 20 + 8 + len(payload)
+	b := make([]byte, total)
+
+	b[0] = 0x45 // versión 4, IHL 5
+	binary.BigEndian.PutUint16(b[2:], uint16(total))
+	binary.BigEndian.PutUint16(b[4:], id)
+	b[8] = 64 // TTL
+	b[9] = 17 // UDP
+	copy(b[12:16], src[:])
+	copy(b[16:20], dst[:])
+	binary.BigEndian.PutUint16(b[10:], ipv4Checksum(b[:20]))
+
+	binary.BigEndian.PutUint16(b[20:], rtpCapturePort)
+	binary.BigEndian.PutUint16(b[22:], rtpCapturePort)
+	binary.BigEndian.PutUint16(b[24:], uint16(8+len(payload)))
+	copy(b[28:], payload)
+	return b
+}
+
+func ipv4Checksum(hdr []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(hdr); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(hdr[i:]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
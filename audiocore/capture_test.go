@@ -0,0 +1,109 @@
+package audiocore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Ejemplo clásico de checksum IPv4 (Wikipedia / RFC 1071)
+func TestIPv4Checksum(t *testing.T) {
+	hdr := []byte{
+		0x45, 0x00, 0x00, 0x73, 0x00, 0x00, 0x40, 0x00, 0x40, 0x11,
+		0x00, 0x00, 0xc0, 0xa8, 0x00, 0x01, 0xc0, 0xa8, 0x00, 0xc7,
+	}
+	if got := ipv4Checksum(hdr); got != 0xb861 {
+		t.Fatalf("checksum = %#04x, want 0xb861", got)
+	}
+	binary.BigEndian.PutUint16(hdr[10:], 0xb861)
+	if got := ipv4Checksum(hdr); got != 0 {
+		t.Errorf("con el checksum puesto la suma debería dar 0, da %#04x", got)
+	}
+}
+
+func TestRTPCapturePcap(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+	c, err := m.newRTPCapture("100-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := [][]byte{
+		{0x80, 0x6f, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0, 1, 0xf8, 0xff, 0xfe},
+		{0x80, 0x6f, 0x00, 0x02, 0, 0, 3, 0xc0, 0, 0, 0, 1, 0xf8},
+		bytes.Repeat([]byte{0xaa}, 200),
+	}
+	for _, p := range payloads {
+		if err := c.writeIncoming(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeIncoming(payloads[0]); err != nil {
+		t.Errorf("escribir tras Close debería descartarse sin error: %v", err)
+	}
+	if filepath.Base(c.path) != "rtp-100-1.pcap" {
+		t.Errorf("path = %s", c.path)
+	}
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Header global
+	if len(b) < 24 {
+		t.Fatalf("archivo de %d bytes", len(b))
+	}
+	le := binary.LittleEndian
+	if le.Uint32(b[0:]) != 0xa1b2c3d4 || le.Uint16(b[4:]) != 2 || le.Uint16(b[6:]) != 4 {
+		t.Errorf("magic/versión = %x", b[:8])
+	}
+	if le.Uint32(b[16:]) != 65535 || le.Uint32(b[20:]) != pcapLinkTypeRaw {
+		t.Errorf("snaplen/linktype = %d/%d", le.Uint32(b[16:]), le.Uint32(b[20:]))
+	}
+
+	// Registros: header de 16 bytes + IPv4 + UDP + RTP
+	off := 24
+	for i, p := range payloads {
+		if off+16 > len(b) {
+			t.Fatalf("registro %d truncado", i)
+		}
+		incl, orig := le.Uint32(b[off+8:]), le.Uint32(b[off+12:])
+		if incl != orig || int(incl) != 28+len(p) {
+			t.Errorf("registro %d: incl/orig = %d/%d, want %d", i, incl, orig, 28+len(p))
+		}
+		if le.Uint32(b[off:]) == 0 {
+			t.Errorf("registro %d sin timestamp", i)
+		}
+		pkt := b[off+16 : off+16+int(incl)]
+		off += 16 + int(incl)
+
+		be := binary.BigEndian
+		if pkt[0] != 0x45 || pkt[9] != 17 || int(be.Uint16(pkt[2:])) != len(pkt) {
+			t.Errorf("registro %d: header IPv4 inválido %x", i, pkt[:20])
+		}
+		if ipv4Checksum(pkt[:20]) != 0 {
+			t.Errorf("registro %d: checksum IPv4 inválido", i)
+		}
+		if be.Uint16(pkt[4:]) != uint16(i+1) {
+			t.Errorf("registro %d: id IPv4 = %d", i, be.Uint16(pkt[4:]))
+		}
+		if !bytes.Equal(pkt[12:16], rtpCaptureRemoteIP[:]) || !bytes.Equal(pkt[16:20], rtpCaptureLocalIP[:]) {
+			t.Errorf("registro %d: direcciones %v -> %v", i, pkt[12:16], pkt[16:20])
+		}
+		if be.Uint16(pkt[20:]) != rtpCapturePort || be.Uint16(pkt[22:]) != rtpCapturePort ||
+			int(be.Uint16(pkt[24:])) != 8+len(p) {
+			t.Errorf("registro %d: header UDP inválido %x", i, pkt[20:28])
+		}
+		if !bytes.Equal(pkt[28:], p) {
+			t.Errorf("registro %d: payload distinto", i)
+		}
+	}
+	if off != len(b) {
+		t.Errorf("sobran %d bytes al final", len(b)-off)
+	}
+}
@@ -0,0 +1,137 @@
+package audiocore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= G.711 (PCMU/PCMA) =========================
+
+// isG711 indica si el codec es µ-law o A-law
+func isG711(mime string) bool {
+	return strings.EqualFold(mime, webrtc.MimeTypePCMU) || strings.EqualFold(mime, webrtc.MimeTypePCMA)
+}
+
+// ulawToLinear decodifica una muestra µ-law (G.711) a PCM de 16 bits
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	t := (int16(u&0x0F) << 3) + 0x84
+	t <<= (u & 0x70) >> 4
+	if u&0x80 != 0 {
+		return 0x84 - t
+	}
+	return t - 0x84
+}
+
+// alawToLinear decodifica una muestra A-law (G.711) a PCM de 16 bits
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	t := int16(a&0x0F) << 4
+	switch seg := (a & 0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+	if a&0x80 != 0 {
+		return t
+	}
+	return -t
+}
+
+// wavRecorder graba G.711 decodificado a WAV PCM 16 bits mono. El header se
+// escribe al abrir con tamaños en 0 y se completa en close.
+type wavRecorder struct {
+	call   *Call
+	f      *os.File
+	decode func(byte) int16
+	meta   *RecordingMeta
+	data   uint32 // bytes de audio escritos
+	buf    []byte
+}
+
+// newWAVRecorder crea audio-<id>.wav y su sidecar para un track PCMU/PCMA
+func newWAVRecorder(call *Call, track *webrtc.TrackRemote) (*wavRecorder, error) {
+	codec := track.Codec()
+	decode := ulawToLinear
+	if strings.EqualFold(codec.MimeType, webrtc.MimeTypePCMA) {
+		decode = alawToLinear
+	}
+	rate := codec.ClockRate
+	if rate == 0 {
+		rate = 8000
+	}
+
+	abs := call.m.recordingPath(fmt.Sprintf("audio-%s.wav", call.ID))
+	f, err := os.Create(abs)
+	if err != nil {
+		return nil, err
+	}
+	w := &wavRecorder{call: call, f: f, decode: decode}
+	if _, err := f.Write(wavHeader(rate, 0)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w.meta = newRecordingMeta(call, track, abs)
+	if err := w.meta.write(); err != nil {
+		call.log.Error("Error escribiendo metadata de grabación", "err", err)
+	}
+	call.log.Info("Grabando audio", "path", abs, "codec", codec.MimeType)
+	return w, nil
+}
+
+// WriteRTP decodifica el payload G.711 y lo agrega al WAV
+func (w *wavRecorder) WriteRTP(pkt *rtp.Packet) error {
+	w.buf = w.buf[:0]
+	for _, b := range pkt.Payload {
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(w.decode(b)))
+	}
+	n, err := w.f.Write(w.buf)
+	w.data += uint32(n)
+	return err
+}
+
+// close completa los tamaños del header RIFF y el sidecar
+func (w *wavRecorder) close() {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], 36+w.data)
+	if _, err := w.f.WriteAt(size[:], 4); err != nil {
+		w.call.log.Error("Error cerrando wav", "err", err)
+	}
+	binary.LittleEndian.PutUint32(size[:], w.data)
+	if _, err := w.f.WriteAt(size[:], 40); err != nil {
+		w.call.log.Error("Error cerrando wav", "err", err)
+	}
+	if err := w.f.Close(); err != nil {
+		w.call.log.Error("Error cerrando wav", "err", err)
+	}
+	w.call.recordingDone(w.meta)
+}
+
+// wavHeader arma el header de 44 bytes de un WAV PCM 16 bits mono
+func wavHeader(rate, dataSize uint32) []byte {
+	const channels, bits = 1, 16
+	h := make([]byte, 0, 44)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, 36+dataSize)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16) // tamaño del chunk fmt
+	h = binary.LittleEndian.AppendUint16(h, 1)  // PCM
+	h = binary.LittleEndian.AppendUint16(h, channels)
+	h = binary.LittleEndian.AppendUint32(h, rate)
+	h = binary.LittleEndian.AppendUint32(h, rate*channels*bits/8) // byte rate
+	h = binary.LittleEndian.AppendUint16(h, channels*bits/8)      // block align
+	h = binary.LittleEndian.AppendUint16(h, bits)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataSize)
+	return h
+}
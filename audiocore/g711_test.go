@@ -0,0 +1,116 @@
+package audiocore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// Valores de referencia de G.711 (tablas de la ITU / g711.c de Sun)
+func TestULawToLinear(t *testing.T) {
+	for u, want := range map[byte]int16{
+		0x00: -32124, // máximo negativo
+		0x80: 32124,  // máximo positivo
+		0xFF: 0,      // silencio
+		0x7F: 0,      // "cero negativo"
+		0x0F: -16764,
+		0x8F: 16764,
+		0x70: -120,
+		0xF0: 120,
+		0x3C: -2364,
+	} {
+		if got := ulawToLinear(u); got != want {
+			t.Errorf("ulawToLinear(%#02x) = %d, want %d", u, got, want)
+		}
+	}
+}
+
+func TestALawToLinear(t *testing.T) {
+	for a, want := range map[byte]int16{
+		0xD5: 8, // silencio
+		0x55: -8,
+		0xAA: 32256, // máximo positivo
+		0x2A: -32256,
+		0x80: 5504,
+		0x00: -5504,
+		0xC5: 264,
+		0x45: -264,
+	} {
+		if got := alawToLinear(a); got != want {
+			t.Errorf("alawToLinear(%#02x) = %d, want %d", a, got, want)
+		}
+	}
+}
+
+// µ-law y A-law son simétricos: el bit de signo solo cambia el signo
+func TestG711Symmetry(t *testing.T) {
+	for b := 0; b < 0x80; b++ {
+		if u, v := ulawToLinear(byte(b)), ulawToLinear(byte(b)|0x80); u != -v {
+			t.Fatalf("µ-law %#02x/%#02x: %d vs %d", b, b|0x80, u, v)
+		}
+		if a, v := alawToLinear(byte(b)), alawToLinear(byte(b)|0x80); a != -v {
+			t.Fatalf("A-law %#02x/%#02x: %d vs %d", b, b|0x80, a, v)
+		}
+	}
+}
+
+func TestIsG711(t *testing.T) {
+	for mime, want := range map[string]bool{
+		"audio/PCMU": true,
+		"audio/pcma": true,
+		"audio/opus": false,
+		"":           false,
+	} {
+		if got := isG711(mime); got != want {
+			t.Errorf("isG711(%q) = %v", mime, got)
+		}
+	}
+}
+
+// Al cerrar, el header RIFF queda con los tamaños reales
+func TestWAVRecorderHeader(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "audio-1-1.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(wavHeader(8000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := newTestManager(t, Config{RecordingDir: dir})
+	call := testCall(m, "1-1")
+	w := &wavRecorder{
+		call:   call,
+		f:      f,
+		decode: ulawToLinear,
+		meta:   &RecordingMeta{File: "audio-1-1.wav", path: filepath.Join(dir, "audio-1-1.json")},
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.WriteRTP(&rtp.Packet{Payload: []byte{0x00, 0x80, 0xFF, 0x7F}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.close()
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const data = 3 * 4 * 2 // 3 paquetes x 4 muestras x 16 bits
+	if len(b) != 44+data {
+		t.Fatalf("largo = %d, want %d", len(b), 44+data)
+	}
+	if !bytes.Equal(b[:44], wavHeader(8000, data)) {
+		t.Errorf("header = %x, want %x", b[:44], wavHeader(8000, data))
+	}
+	if got := int16(binary.LittleEndian.Uint16(b[44:])); got != -32124 {
+		t.Errorf("primera muestra = %d, want -32124", got)
+	}
+	if string(b[8:16]) != "WAVEfmt " || binary.LittleEndian.Uint32(b[24:]) != 8000 {
+		t.Errorf("fmt inválido: %x", b[:44])
+	}
+}
@@ -0,0 +1,198 @@
+// Package audiocore maneja las llamadas WebRTC de audio sin depender de HTTP:
+// arma la PeerConnection de cada llamada, graba el audio (y video) entrante,
+// emite un OGG Opus o silencio hacia el otro extremo y lleva el registro de
+// llamadas activas. El servidor (package main) es una capa HTTP encima de un
+// Manager; cualquier otro programa Go lo puede usar igual:
+//
+//	m := audiocore.NewManager(audiocore.Config{RecordingDir: "grabaciones"})
+//	call, err := m.CreateCall(audiocore.CallOptions{OutOGGPath: "bienvenida.ogg"})
+//	if err != nil { ... }
+//	answer, err := call.Answer(offer, nil, false) // oferta del cliente
+//	if err != nil { ... }                         // la llamada ya quedó cerrada
+//	call.MarkStarted(nil)
+//	...
+//	m.CloseCall(call)
+package audiocore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"webrtc-audio-server/tasks"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Manager =========================
+
+// Directorio de grabaciones si Config.RecordingDir está vacío
+const DefaultRecordingDir = "recorder"
+
+// Config es la configuración común a todas las llamadas de un Manager. Los
+// valores cero son válidos: sin límites, sin eventos, sin emisión de silencio
+// y grabaciones en DefaultRecordingDir.
+type Config struct {
+	Logger *slog.Logger // nil = slog.Default()
+
+	RTC   webrtc.Configuration // servidores ICE
+	Media MediaProfile         // codecs del MediaEngine (ver NewMediaEngine)
+
+	// SettingEngine arma el SettingEngine de cada llamada (redes ICE, puertos,
+	// rol DTLS); nil = el de pion sin cambios
+	SettingEngine func() (webrtc.SettingEngine, error)
+
+	MaxCalls        int           // llamadas simultáneas (0 = sin límite)
+	MaxCallDuration time.Duration // cuelga a este tiempo de conectada (0 = sin tope)
+
+	RecordingDir         string        // relativo al directorio de trabajo si no es absoluto
+	RecordingMaxDuration time.Duration // rota el OGG entrante (0 = sin tope)
+	RecordingMaxBytes    int64         // rota el OGG entrante (0 = sin tope)
+	RecordVideo          bool          // graba el video entrante (si no, se ignora)
+
+	ComfortNoise   bool          // silencio Opus mientras no se emite audio
+	OutLoop        int           // veces que se emite el OGG (0 o 1 = una, -1 = infinito)
+	OutTimeout     time.Duration // corta la emisión del OGG (0 = sin timeout)
+	CloseOnTimeout bool          // cuelga la llamada al vencer OutTimeout
+
+	// OnEvent recibe los eventos de ciclo de vida (EventCallStarted, ...). Se
+	// llama desde las goroutines de la llamada, así que no debe bloquear.
+	OnEvent func(event, callID string, data any)
+
+	// OnRecording se llama con cada grabación terminada: el archivo ya está
+	// cerrado y el sidecar completo (p.ej. para subirla a otro lado)
+	OnRecording func(call *Call, rec *RecordingMeta)
+}
+
+// Manager crea y registra llamadas. Es seguro para uso concurrente.
+type Manager struct {
+	cfg          Config
+	log          *slog.Logger
+	recordingDir string
+
+	calls  sync.Map     // map[string]*Call
+	active atomic.Int64 // llamadas con lugar reservado (ver acquireSlot)
+
+	// Receptores de OnTrack en curso: cierran el WAV/OGG y completan el
+	// sidecar después de colgar (ver Shutdown)
+	receivers tasks.Group
+}
+
+// ErrCallLimit indica que se alcanzó Config.MaxCalls
+var ErrCallLimit = errors.New("límite de llamadas simultáneas alcanzado")
+
+// NewManager crea un Manager con cfg
+func NewManager(cfg Config) *Manager {
+	m := &Manager{cfg: cfg, log: cfg.Logger}
+	if m.log == nil {
+		m.log = slog.Default()
+	}
+	dir := cfg.RecordingDir
+	if dir == "" {
+		dir = DefaultRecordingDir
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	m.recordingDir = dir
+	return m
+}
+
+// Get busca una llamada activa por id
+func (m *Manager) Get(id string) (*Call, bool) {
+	if v, ok := m.calls.Load(id); ok {
+		return v.(*Call), true
+	}
+	return nil, false
+}
+
+// Calls devuelve las llamadas activas, de la más vieja a la más nueva
+func (m *Manager) Calls() []*Call {
+	var out []*Call
+	m.calls.Range(func(_, v any) bool {
+		out = append(out, v.(*Call))
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// ActiveCalls cuenta las llamadas con lugar reservado, incluidas las que
+// todavía están negociando
+func (m *Manager) ActiveCalls() int64 { return m.active.Load() }
+
+// Shutdown cuelga las llamadas activas, deja de aceptar tracks nuevos y
+// espera a que las PeerConnection terminen de cerrarse y a que queden
+// cerradas todas las grabaciones, o a que venza ctx (devuelve ctx.Err()).
+// No impide crear llamadas: eso queda a cargo de quien apaga.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var closing tasks.Group
+	for _, c := range m.Calls() {
+		closing.Go(func() { m.CloseCall(c) }) // PC.Close bloquea hasta terminar el teardown
+	}
+	if err := closing.Wait(ctx); err != nil {
+		return err
+	}
+	return m.receivers.Wait(ctx)
+}
+
+// acquireSlot reserva un lugar para una llamada nueva; false si ya se
+// alcanzó Config.MaxCalls
+func (m *Manager) acquireSlot() bool {
+	for {
+		n := m.active.Load()
+		if m.cfg.MaxCalls > 0 && n >= int64(m.cfg.MaxCalls) {
+			return false
+		}
+		if m.active.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (m *Manager) releaseSlot() { m.active.Add(-1) }
+
+// emit entrega un evento de ciclo de vida a Config.OnEvent
+func (m *Manager) emit(event, callID string, data any) {
+	if m.cfg.OnEvent != nil {
+		m.cfg.OnEvent(event, callID, data)
+	}
+}
+
+// CloseCall avisa por Done, saca la llamada del registro y cierra la
+// PeerConnection. Se puede llamar varias veces (hangup, cambio de estado,
+// inactividad): solo la primera tiene efecto.
+func (m *Manager) CloseCall(c *Call) {
+	first := false
+	c.closeOnce.Do(func() {
+		first = true
+		close(c.Done)
+		m.calls.Delete(c.ID)
+		m.releaseSlot()
+	})
+	if !first {
+		return
+	}
+	info := c.Info() // antes de Close, para reportar el estado real
+	_ = c.PC.Close()
+	if c.capture != nil {
+		if err := c.capture.Close(); err != nil {
+			c.log.Error("Error cerrando captura RTP", "err", err)
+		}
+	}
+	c.log.Info("Call cerrada y eliminada")
+
+	// Una llamada que falló en la negociación nunca emitió call_started
+	if c.lifecycle.Swap(callClosed) != callAnnounced {
+		return
+	}
+	if info.ConnectionState == webrtc.PeerConnectionStateFailed.String() {
+		m.emit(EventCallFailed, c.ID, info)
+	}
+	m.emit(EventCallEnded, c.ID, info)
+}
@@ -0,0 +1,154 @@
+package audiocore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Ciclo de vida de una llamada usando solo la API pública, sin HTTP
+func TestManagerCallLifecycle(t *testing.T) {
+	m, rec := newTestManager(t, Config{MaxCalls: 1})
+
+	call, err := m.CreateCall(CallOptions{RemoteAddr: "192.0.2.1:5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := m.Get(call.ID); !ok || got != call {
+		t.Fatalf("Get(%q) = %v, %v", call.ID, got, ok)
+	}
+	if calls := m.Calls(); len(calls) != 1 || calls[0] != call {
+		t.Fatalf("Calls() = %v", calls)
+	}
+	if n := m.ActiveCalls(); n != 1 {
+		t.Fatalf("ActiveCalls() = %d, want 1", n)
+	}
+	if info := call.Info(); info.ID != call.ID || info.RemoteAddr != "192.0.2.1:5000" || info.Playback != "idle" {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	// Con el único lugar ocupado no entra otra
+	if _, err := m.CreateCall(CallOptions{}); !errors.Is(err, ErrCallLimit) {
+		t.Fatalf("segunda CreateCall: err = %v, want ErrCallLimit", err)
+	}
+
+	call.MarkStarted(nil)
+	m.CloseCall(call)
+	select {
+	case <-call.Done:
+	default:
+		t.Fatal("CloseCall no cerró Done")
+	}
+	if _, ok := m.Get(call.ID); ok {
+		t.Error("la llamada sigue en el registro")
+	}
+	if n := m.ActiveCalls(); n != 0 {
+		t.Errorf("ActiveCalls() = %d tras colgar, want 0", n)
+	}
+	got := rec.names()
+	if len(got) != 2 || got[0] != EventCallStarted || got[1] != EventCallEnded {
+		t.Errorf("eventos = %v, want [%s %s]", got, EventCallStarted, EventCallEnded)
+	}
+
+	// Liberado el lugar, entra la siguiente
+	next, err := m.CreateCall(CallOptions{})
+	if err != nil {
+		t.Fatalf("CreateCall tras colgar: %v", err)
+	}
+	m.CloseCall(next)
+}
+
+// Shutdown cuelga las llamadas activas y, con una grabación que no termina
+// de cerrarse, vuelve al vencer ctx; después ya no admite tracks nuevos
+func TestManagerShutdown(t *testing.T) {
+	m, rec := newTestManager(t, Config{})
+	call, err := m.CreateCall(CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call.MarkStarted(nil)
+
+	release := make(chan struct{})
+	defer close(release)
+	m.receivers.Go(func() { <-release }) // receptor trabado
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Shutdown tardó %v con la gracia vencida", d)
+	}
+
+	select {
+	case <-call.Done:
+	default:
+		t.Fatal("Shutdown no colgó la llamada")
+	}
+	if got := rec.names(); len(got) != 2 || got[1] != EventCallEnded {
+		t.Errorf("eventos = %v, want call_ended al final", got)
+	}
+	if m.receivers.Go(func() {}) {
+		t.Error("Shutdown siguió admitiendo receptores")
+	}
+}
+
+// Negociación completa contra un PeerConnection de pion como cliente
+func TestManagerAnswer(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offerer.Close()
+	if _, err := offerer.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call, err := m.CreateCall(CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.CloseCall(call)
+	answer, err := call.Answer(offer, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer.Type != webrtc.SDPTypeAnswer || !strings.Contains(answer.SDP, "m=audio ") {
+		t.Fatalf("answer inesperada (%s):\n%s", answer.Type, answer.SDP)
+	}
+	if !strings.Contains(answer.SDP, "a=recvonly") {
+		t.Errorf("sin emisión la answer debería ser recvonly:\n%s", answer.SDP)
+	}
+}
+
+// Una oferta inválida devuelve ErrRemoteDescription y deja la llamada cerrada
+func TestManagerAnswerInvalidOffer(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+
+	call, err := m.CreateCall(CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = call.Answer(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: "v=0\r\n"}, nil, true)
+	if !errors.Is(err, ErrRemoteDescription) {
+		t.Fatalf("err = %v, want ErrRemoteDescription", err)
+	}
+	if _, ok := m.Get(call.ID); ok {
+		t.Error("la llamada sigue en el registro")
+	}
+	if n := m.ActiveCalls(); n != 0 {
+		t.Errorf("ActiveCalls() = %d, want 0", n)
+	}
+}
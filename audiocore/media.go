@@ -0,0 +1,181 @@
+package audiocore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Codecs =========================
+
+// Payload types fijos del perfil "opus" (los mismos que usa Chrome)
+const (
+	opusPayloadType = 111
+	pcmuPayloadType = 0 // estáticos (RFC 3551)
+	pcmaPayloadType = 8
+	vp8PayloadType  = 96
+	h264PayloadType = 102
+)
+
+// Feedback RTCP anunciado para video
+var videoRTCPFeedback = []webrtc.RTCPFeedback{
+	{Type: "goog-remb"},
+	{Type: "ccm", Parameter: "fir"},
+	{Type: "nack"},
+	{Type: "nack", Parameter: "pli"},
+}
+
+// Perfiles de codecs (ver NewMediaEngine)
+const (
+	MediaProfileDefault = "default" // RegisterDefaultCodecs de pion
+	MediaProfileOpus    = "opus"    // solo Opus (+ Video si se define)
+)
+
+// MediaProfile define qué codecs se registran en el MediaEngine de cada
+// llamada
+type MediaProfile struct {
+	Name  string // MediaProfileDefault (también "") o MediaProfileOpus
+	Video string // solo perfil opus: "" (sin video), vp8 o h264
+	G711  bool   // solo perfil opus: también PCMU/PCMA, detrás de Opus
+
+	OpusFmtp string // fmtp de Opus preferido en la answer ("" = el de pion)
+}
+
+// Default del fmtp de Opus (el mismo que registra pion)
+const defaultOpusFmtp = "minptime=10;useinbandfec=1"
+
+// opusFmtp devuelve el fmtp con el que se registra/prefiere Opus
+func (p MediaProfile) opusFmtp() string {
+	if p.OpusFmtp != "" {
+		return p.OpusFmtp
+	}
+	return defaultOpusFmtp
+}
+
+// opusPreference es la lista para SetCodecPreferences: solo Opus con el
+// fmtp configurado. PayloadType queda en 0 para que pion use el negociado.
+func (p MediaProfile) opusPreference() []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: p.opusFmtp(),
+		},
+	}}
+}
+
+// Parámetros fmtp de Opus aceptados (RFC 7587, 6.1) con su rango válido
+var opusFmtpRanges = map[string][2]int{
+	"minptime":             {3, 120},
+	"ptime":                {3, 120},
+	"maxptime":             {3, 120},
+	"useinbandfec":         {0, 1},
+	"usedtx":               {0, 1},
+	"stereo":               {0, 1},
+	"sprop-stereo":         {0, 1},
+	"cbr":                  {0, 1},
+	"maxaveragebitrate":    {6000, 510000},
+	"maxplaybackrate":      {8000, 48000},
+	"sprop-maxcapturerate": {8000, 48000},
+}
+
+// ValidateOpusFmtp verifica que raw sea "k=v;k=v" con parámetros Opus
+// conocidos y valores dentro de rango; "" es válido (sin preferencia)
+func ValidateOpusFmtp(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(raw, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return fmt.Errorf("parámetro sin valor: %q", kv)
+		}
+		rng, known := opusFmtpRanges[k]
+		if !known {
+			return fmt.Errorf("parámetro Opus desconocido: %q", k)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < rng[0] || n > rng[1] {
+			return fmt.Errorf("%s=%q fuera de rango (%d-%d)", k, v, rng[0], rng[1])
+		}
+	}
+	return nil
+}
+
+func (p MediaProfile) isDefault() bool {
+	return p.Name == "" || p.Name == MediaProfileDefault
+}
+
+// hasVideo indica si con este perfil se puede negociar video
+func (p MediaProfile) hasVideo() bool {
+	return p.isDefault() || p.Video != ""
+}
+
+// NewMediaEngine arma el MediaEngine del perfil:
+//   - default: RegisterDefaultCodecs de pion (todo lo que pion conoce)
+//   - opus: solo Opus (y G.711 si se pidió) y, si se pidió, un codec de
+//     video, con payload types explícitos; el SDP queda acotado a lo que el
+//     servidor maneja. Los codecs G.711 se graban a WAV (ver wavRecorder).
+func NewMediaEngine(p MediaProfile) (*webrtc.MediaEngine, error) {
+	m := &webrtc.MediaEngine{}
+	if p.isDefault() {
+		return m, m.RegisterDefaultCodecs()
+	}
+
+	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: p.opusFmtp(),
+		},
+		PayloadType: opusPayloadType,
+	}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, err
+	}
+
+	if p.G711 {
+		for _, c := range []webrtc.RTPCodecParameters{
+			{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000}, PayloadType: pcmuPayloadType},
+			{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMA, ClockRate: 8000}, PayloadType: pcmaPayloadType},
+		} {
+			if err := m.RegisterCodec(c, webrtc.RTPCodecTypeAudio); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var video webrtc.RTPCodecParameters
+	switch p.Video {
+	case "":
+		return m, nil
+	case "vp8":
+		video = webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     webrtc.MimeTypeVP8,
+				ClockRate:    90000,
+				RTCPFeedback: videoRTCPFeedback,
+			},
+			PayloadType: vp8PayloadType,
+		}
+	case "h264":
+		video = webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     webrtc.MimeTypeH264,
+				ClockRate:    90000,
+				SDPFmtpLine:  "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+				RTCPFeedback: videoRTCPFeedback,
+			},
+			PayloadType: h264PayloadType,
+		}
+	default:
+		return nil, fmt.Errorf("codec de video no soportado: %q", p.Video)
+	}
+	if err := m.RegisterCodec(video, webrtc.RTPCodecTypeVideo); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
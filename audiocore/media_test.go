@@ -0,0 +1,43 @@
+package audiocore
+
+import "testing"
+
+func TestValidateOpusFmtp(t *testing.T) {
+	valid := []string{
+		"",
+		"useinbandfec=1",
+		"useinbandfec=1;stereo=0;maxaveragebitrate=32000",
+		"minptime=10; useinbandfec=1",
+		"maxplaybackrate=16000;usedtx=1;cbr=0",
+	}
+	for _, raw := range valid {
+		if err := ValidateOpusFmtp(raw); err != nil {
+			t.Errorf("ValidateOpusFmtp(%q) = %v", raw, err)
+		}
+	}
+	invalid := []string{
+		"useinbandfec",              // sin valor
+		"useinbandfec=2",            // fuera de rango
+		"maxaveragebitrate=1000",    // menos de 6000
+		"maxaveragebitrate=600000",  // más de 510000
+		"stereo=si",                 // no numérico
+		"profile-level-id=42e01f",   // no es de Opus
+		"useinbandfec=1;;stereo=0",  // vacío en el medio
+		"minptime=10;maxptime=1000", // ptime fuera de rango
+	}
+	for _, raw := range invalid {
+		if err := ValidateOpusFmtp(raw); err == nil {
+			t.Errorf("ValidateOpusFmtp(%q) debería fallar", raw)
+		}
+	}
+}
+
+func TestMediaProfileOpusFmtp(t *testing.T) {
+	if got := (MediaProfile{}).opusFmtp(); got != defaultOpusFmtp {
+		t.Errorf("sin OPUS_FMTP = %q, want %q", got, defaultOpusFmtp)
+	}
+	pref := MediaProfile{OpusFmtp: "stereo=0"}.opusPreference()
+	if len(pref) != 1 || pref[0].MimeType != "audio/opus" || pref[0].SDPFmtpLine != "stereo=0" || pref[0].PayloadType != 0 {
+		t.Errorf("opusPreference = %+v", pref)
+	}
+}
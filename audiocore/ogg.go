@@ -0,0 +1,84 @@
+package audiocore
+
+import (
+	"errors"
+	"io"
+)
+
+// ========================= Lectura de OGG por paquete =========================
+
+var (
+	errOGGBadPage   = errors.New("página OGG inválida")
+	errOGGShortPage = errors.New("página OGG incompleta")
+)
+
+// oggPacketReader lee de a un paquete un OGG de un solo stream lógico.
+// oggreader.ParseNextPage devuelve la página entera y una página de opusenc
+// o ffmpeg trae decenas de paquetes Opus; acá la tabla de segmentos (lacing)
+// de cada página separa los paquetes y une los que siguen en la página
+// siguiente (granule -1). No verifica el CRC.
+type oggPacketReader struct {
+	r       io.Reader
+	lacing  []byte // segmentos de la página actual que faltan leer
+	payload []byte // datos de esos segmentos
+	partial []byte // paquete empezado que sigue en el próximo segmento
+}
+
+func newOGGPacketReader(r io.Reader) *oggPacketReader {
+	return &oggPacketReader{r: r}
+}
+
+// nextPacket devuelve el siguiente paquete completo, incluidos OpusHead y
+// OpusTags; io.EOF al terminar el stream
+func (o *oggPacketReader) nextPacket() ([]byte, error) {
+	for {
+		// Un paquete termina en el primer segmento de menos de 255 bytes
+		for len(o.lacing) > 0 {
+			n := int(o.lacing[0])
+			o.lacing = o.lacing[1:]
+			o.partial = append(o.partial, o.payload[:n]...)
+			o.payload = o.payload[n:]
+			if n < 255 {
+				pkt := o.partial
+				o.partial = nil
+				return pkt, nil
+			}
+		}
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readPage carga la tabla de segmentos y los datos de la próxima página
+func (o *oggPacketReader) readPage() error {
+	var h [27]byte
+	if _, err := io.ReadFull(o.r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return errOGGShortPage
+		}
+		return err // io.EOF entre páginas: fin del stream
+	}
+	if string(h[:4]) != "OggS" {
+		return errOGGBadPage
+	}
+	// Sin el flag de continuación, un paquete a medias quedó cortado
+	if h[5]&0x01 == 0 {
+		o.partial = nil
+	}
+
+	lacing := make([]byte, h[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return errOGGShortPage
+	}
+	size := 0
+	for _, n := range lacing {
+		size += int(n)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(o.r, payload); err != nil {
+		return errOGGShortPage
+	}
+	o.lacing, o.payload = lacing, payload
+	return nil
+}
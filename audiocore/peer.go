@@ -0,0 +1,306 @@
+package audiocore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= PeerConnection de la llamada =========================
+
+// CallOptions son los ajustes de una llamada en particular
+type CallOptions struct {
+	RemoteAddr  string        // quién pidió la llamada (logs, Info, sidecars)
+	IdleTimeout time.Duration // cuelga sin RTP entrante (0 = deshabilitado)
+	CaptureRTP  bool          // guarda el RTP entrante en rtp-<id>.pcap
+	OutOGGPath  string        // OGG Opus a emitir al conectar ("" = nada)
+	Video       bool          // la oferta trae m=video (ver OffersVideo)
+}
+
+// Errores de Call.Answer según qué parte de lo que mandó el otro extremo
+// falló; el resto de los errores son internos
+var (
+	ErrRemoteDescription = errors.New("SetRemoteDescription falló")
+	ErrRemoteCandidate   = errors.New("AddICECandidate falló")
+)
+
+// CreateCall reserva un lugar (ErrCallLimit si no hay), crea la
+// PeerConnection y registra la llamada. Deja listos el transceiver de audio
+// (sendrecv si hay OGG o comfort noise, si no recvonly), el de video si
+// opts.Video, la grabación de lo entrante (SetupAudioReceiver) y la emisión
+// al conectar (SetupAudioSender). Falta aplicar la oferta con Call.Answer.
+func (m *Manager) CreateCall(opts CallOptions) (*Call, error) {
+	// 1) MediaEngine según el perfil (default o solo Opus)
+	profile := m.cfg.Media
+	me, err := NewMediaEngine(profile)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo registrar codecs: %w", err)
+	}
+
+	// 2) SettingEngine: rol DTLS y redes ICE
+	var se webrtc.SettingEngine
+	if m.cfg.SettingEngine != nil {
+		if se, err = m.cfg.SettingEngine(); err != nil {
+			return nil, fmt.Errorf("no se pudo configurar el transporte: %w", err)
+		}
+	}
+
+	api := webrtc.NewAPI(
+		webrtc.WithMediaEngine(me),
+		webrtc.WithSettingEngine(se),
+	)
+
+	// 3) Reservar lugar y crear PeerConnection. A partir del registro el
+	//    lugar lo libera CloseCall.
+	if !m.acquireSlot() {
+		return nil, ErrCallLimit
+	}
+	peer, err := api.NewPeerConnection(m.cfg.RTC)
+	if err != nil {
+		m.releaseSlot()
+		return nil, fmt.Errorf("error creando PeerConnection: %w", err)
+	}
+	m.log.Debug("PeerConnection creado")
+
+	// ---- Crear y registrar la "Call" ----
+	callID := newCallID()
+	call := &Call{
+		ID:          callID,
+		PC:          peer,
+		Done:        make(chan struct{}),
+		playback:    make(chan playbackCmd, 4),
+		IdleTimeout: opts.IdleTimeout,
+		StartedAt:   time.Now(),
+		RemoteAddr:  opts.RemoteAddr,
+		m:           m,
+		log:         m.log.With("call_id", callID),
+	}
+	if opts.CaptureRTP {
+		if call.capture, err = m.newRTPCapture(callID); err != nil {
+			call.log.Error("No se pudo crear la captura RTP", "err", err)
+		} else {
+			call.log.Info("Captura RTP activa", "path", call.capture.path)
+		}
+	}
+	m.calls.Store(call.ID, call)
+	call.log.Info("Call creada")
+
+	// 4) Logs detallados de estados/negociación
+	peer.OnICEConnectionStateChange(func(s webrtc.ICEConnectionState) {
+		call.log.Info("ICE state", "state", s.String())
+	})
+	peer.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		call.log.Info("PC state", "state", s.String())
+		if s == webrtc.PeerConnectionStateConnected {
+			call.armMaxDuration(m.cfg.MaxCallDuration)
+		}
+		if s == webrtc.PeerConnectionStateFailed ||
+			s == webrtc.PeerConnectionStateClosed {
+			m.CloseCall(call)
+		}
+	})
+	peer.OnSignalingStateChange(func(s webrtc.SignalingState) {
+		call.log.Debug("Signaling state", "state", s.String())
+	})
+	peer.OnNegotiationNeeded(func() {
+		call.log.Debug("Negotiation needed")
+	})
+	peer.OnICEGatheringStateChange(func(s webrtc.ICEGathererState) {
+		call.log.Debug("ICE gathering state", "state", s.String())
+	})
+
+	// 5) Transceiver de audio:
+	//    - si vamos a ENVIAR OGG o silencio (ComfortNoise): SENDRECV
+	//    - si no enviamos: RECVONLY
+	sending := opts.OutOGGPath != "" || m.cfg.ComfortNoise
+	dir := webrtc.RTPTransceiverDirectionRecvonly
+	if sending {
+		dir = webrtc.RTPTransceiverDirectionSendrecv
+	}
+	audioTrans, err := peer.AddTransceiverFromKind(
+		webrtc.RTPCodecTypeAudio,
+		webrtc.RTPTransceiverInit{Direction: dir},
+	)
+	if err != nil {
+		call.log.Error("AddTransceiverFromKind falló", "kind", "audio", "err", err)
+	} else if profile.OpusFmtp != "" {
+		// OpusFmtp: la answer ofrece solo Opus con esos parámetros
+		if err := audioTrans.SetCodecPreferences(profile.opusPreference()); err != nil {
+			call.log.Error("SetCodecPreferences falló", "err", err)
+		}
+	}
+
+	//    Transceiver de video RECVONLY solo si la oferta trae m=video y el
+	//    perfil de codecs tiene video
+	if opts.Video && profile.hasVideo() {
+		if _, err := peer.AddTransceiverFromKind(
+			webrtc.RTPCodecTypeVideo,
+			webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly},
+		); err != nil {
+			call.log.Error("AddTransceiverFromKind falló", "kind", "video", "err", err)
+		} else {
+			call.log.Info("Oferta con video: transceiver recvonly añadido", "record", m.cfg.RecordVideo)
+		}
+	}
+
+	// 6) Recolectar candidatos locales (se devuelven en la answer o, con
+	//    trickle, por LocalCandidates)
+	peer.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			call.log.Debug("Nuevo ICE candidate local", "candidate", c.String())
+		} else {
+			call.log.Debug("Recolección de ICE finalizada")
+		}
+		call.addLocalCandidate(c)
+	})
+
+	// 7) OnTrack: guardar audio entrante (y video si está habilitado)
+	peer.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		// Shutdown espera a que la grabación quede cerrada; apagando ya no
+		// se graba nada nuevo
+		ok := m.receivers.Go(func() {
+			go drainReceiverRTCP(call, receiver)
+
+			switch {
+			case track.Kind() == webrtc.RTPCodecTypeAudio:
+				m.SetupAudioReceiver(call, track)
+			case track.Kind() == webrtc.RTPCodecTypeVideo && m.cfg.RecordVideo:
+				m.setupVideoReceiver(call, track)
+			default:
+				call.log.Info("Track entrante ignorado", "kind", track.Kind().String())
+			}
+		})
+		if !ok {
+			call.log.Warn("Track entrante ignorado: apagando", "kind", track.Kind().String())
+		}
+	})
+
+	// 8) Emisión de OGG y/o silencio (arranca cuando PC=connected)
+	if sending && audioTrans != nil {
+		if err := m.SetupAudioSender(call, audioTrans, opts.OutOGGPath); err != nil {
+			call.log.Error("No se pudo preparar el audio saliente", "err", err)
+		}
+	}
+	return call, nil
+}
+
+// SetupAudioSender conecta una pista Opus local al sender de trans y, cuando
+// la PeerConnection pasa a connected, empieza a emitir oggPath ("" = nada) y,
+// con Config.ComfortNoise, silencio mientras no haya audio.
+func (m *Manager) SetupAudioSender(call *Call, trans *webrtc.RTPTransceiver, oggPath string) error {
+	call.log.Info("OUTGOING: preparado para enviar audio", "path", oggPath,
+		"comfort_noise", m.cfg.ComfortNoise, "timeout", m.cfg.OutTimeout)
+
+	// Creamos pista local "sample" Opus y la conectamos al sender del transceiver
+	trackLocal, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeOpus,
+			ClockRate: 48000,
+			Channels:  2,
+		},
+		"server-audio", "pion",
+	)
+	if err != nil {
+		return fmt.Errorf("NewTrackLocalStaticSample falló: %w", err)
+	}
+	if err := trans.Sender().ReplaceTrack(trackLocal); err != nil {
+		return fmt.Errorf("ReplaceTrack falló: %w", err)
+	}
+
+	// drenar RTCP para evitar bloqueo del sender
+	go func(ss *webrtc.RTPSender) {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := ss.Read(buf); err != nil {
+				return
+			}
+		}
+	}(trans.Sender())
+
+	// IMPORTANTE: empieza a enviar SOLO cuando la PC está conectada
+	call.PC.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		call.log.Info("PC state", "state", s.String())
+
+		if s == webrtc.PeerConnectionStateConnected {
+			call.armMaxDuration(m.cfg.MaxCallDuration)
+
+			if oggPath != "" {
+				call.log.Info("OUTGOING: conexión lista, comenzando envío OGG")
+				go m.sendOGGAudio(call, trackLocal, oggPath)
+			}
+			if m.cfg.ComfortNoise {
+				go sendComfortNoise(call, trackLocal)
+			}
+		}
+
+		if s == webrtc.PeerConnectionStateFailed || s == webrtc.PeerConnectionStateClosed {
+			m.CloseCall(call)
+		}
+	})
+	return nil
+}
+
+// Answer aplica la oferta remota y sus candidatos, crea la answer y la
+// devuelve. Sin trickle espera a que termine el gathering, así la answer ya
+// trae todos los candidatos; con trickle vuelve enseguida y el resto se lee
+// con LocalCandidates. Si algo falla la llamada queda cerrada.
+func (c *Call) Answer(offer webrtc.SessionDescription, candidates []webrtc.ICECandidateInit, trickle bool) (webrtc.SessionDescription, error) {
+	peer := c.PC
+	if err := peer.SetRemoteDescription(offer); err != nil {
+		c.m.CloseCall(c)
+		return webrtc.SessionDescription{}, fmt.Errorf("%w: %v", ErrRemoteDescription, err)
+	}
+	c.log.Debug("RemoteDescription establecida")
+
+	for _, cand := range candidates {
+		if err := peer.AddICECandidate(cand); err != nil {
+			c.m.CloseCall(c)
+			return webrtc.SessionDescription{}, fmt.Errorf("%w: %v", ErrRemoteCandidate, err)
+		}
+		c.log.Debug("ICE candidate remoto añadido", "candidate", cand.Candidate)
+	}
+
+	answer, err := peer.CreateAnswer(nil)
+	if err != nil {
+		c.m.CloseCall(c)
+		return webrtc.SessionDescription{}, fmt.Errorf("CreateAnswer falló: %w", err)
+	}
+	c.log.Debug("Answer creada")
+
+	gatherComplete := webrtc.GatheringCompletePromise(peer)
+	if err := peer.SetLocalDescription(answer); err != nil {
+		c.m.CloseCall(c)
+		return webrtc.SessionDescription{}, fmt.Errorf("SetLocalDescription falló: %w", err)
+	}
+	if trickle {
+		c.log.Info("LocalDescription establecida, trickle ICE activo")
+	} else {
+		c.log.Debug("LocalDescription establecida, esperando gathering")
+		<-gatherComplete
+		c.log.Debug("Gathering completado")
+	}
+
+	// (Útil para verificar que quedó a=sendrecv (si emites) y a=setup:active)
+	c.log.Debug("Local SDP generado", "sdp", peer.LocalDescription().SDP)
+	return *peer.LocalDescription(), nil
+}
+
+// lee RTCP del lado receptor para que los reports no se acumulen; termina
+// cuando el receiver se detiene (fin del track o llamada cerrada)
+func drainReceiverRTCP(call *Call, rr *webrtc.RTPReceiver) {
+	for {
+		pkts, _, err := rr.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, p := range pkts {
+			if sr, ok := p.(*rtcp.SenderReport); ok {
+				call.log.Debug("RTCP SR recibido", "ssrc", sr.SSRC,
+					"packets", sr.PacketCount, "octets", sr.OctetCount)
+			}
+		}
+	}
+}
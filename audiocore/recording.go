@@ -0,0 +1,105 @@
+package audiocore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Grabaciones =========================
+
+// RecordingDir es el directorio absoluto donde se guardan las grabaciones
+func (m *Manager) RecordingDir() string { return m.recordingDir }
+
+// recordingPath arma la ruta absoluta donde se guarda una grabación
+func (m *Manager) recordingPath(filename string) string {
+	return filepath.Join(m.recordingDir, filename)
+}
+
+// EnsureRecordingDir crea el directorio de grabaciones si no existe
+func (m *Manager) EnsureRecordingDir() error {
+	if err := os.MkdirAll(m.recordingDir, 0o755); err != nil {
+		return fmt.Errorf("no se pudo crear el directorio de grabaciones %s: %w", m.recordingDir, err)
+	}
+	return nil
+}
+
+// RecordingMeta es el sidecar JSON (<grabación sin extensión>.json) que
+// acompaña a cada grabación. Se escribe al empezar y se completa con el fin
+// y la duración cuando termina el track.
+type RecordingMeta struct {
+	CallID      string     `json:"call_id"`
+	File        string     `json:"file"`
+	Kind        string     `json:"kind"`
+	Codec       string     `json:"codec"`
+	SampleRate  uint32     `json:"sample_rate"`
+	Channels    uint16     `json:"channels,omitempty"`
+	SSRC        uint32     `json:"ssrc"`
+	RemoteAddr  string     `json:"remote_addr"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	DurationSec float64    `json:"duration_sec,omitempty"`
+
+	path string // del sidecar
+}
+
+// newRecordingMeta arma el sidecar de la grabación file del track
+func newRecordingMeta(call *Call, track *webrtc.TrackRemote, file string) *RecordingMeta {
+	codec := track.Codec()
+	return &RecordingMeta{
+		CallID:     call.ID,
+		File:       filepath.Base(file),
+		Kind:       track.Kind().String(),
+		Codec:      codec.MimeType,
+		SampleRate: codec.ClockRate,
+		Channels:   codec.Channels,
+		SSRC:       uint32(track.SSRC()),
+		RemoteAddr: call.RemoteAddr,
+		StartedAt:  time.Now(),
+		path:       strings.TrimSuffix(file, filepath.Ext(file)) + ".json",
+	}
+}
+
+// Path es la ruta de la grabación
+func (r *RecordingMeta) Path() string { return filepath.Join(filepath.Dir(r.path), r.File) }
+
+// SidecarPath es la ruta del sidecar JSON
+func (r *RecordingMeta) SidecarPath() string { return r.path }
+
+// write guarda el sidecar; va a un temporal y se renombra para no dejar
+// JSON a medio escribir
+func (r *RecordingMeta) write() error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// finish completa fin y duración y reescribe el sidecar
+func (r *RecordingMeta) finish() error {
+	now := time.Now()
+	r.EndedAt = &now
+	r.DurationSec = now.Sub(r.StartedAt).Seconds()
+	return r.write()
+}
+
+// recordingDone completa el sidecar de una grabación ya cerrada y la pasa a
+// Config.OnRecording
+func (c *Call) recordingDone(meta *RecordingMeta) {
+	if err := meta.finish(); err != nil {
+		c.log.Error("Error cerrando metadata de grabación", "err", err)
+	}
+	if c.m.cfg.OnRecording != nil {
+		c.m.cfg.OnRecording(c, meta)
+	}
+}
@@ -0,0 +1,81 @@
+package audiocore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/h264writer"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+)
+
+// ========================= Recepción de video =========================
+
+// OffersVideo indica si la oferta remota trae alguna sección m=video (ver
+// CallOptions.Video)
+func OffersVideo(offer webrtc.SessionDescription) bool {
+	for _, line := range strings.Split(offer.SDP, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "m=video ") {
+			return true
+		}
+	}
+	return false
+}
+
+// setupVideoReceiver guarda el video entrante: VP8 en IVF y H264 en Annex-B.
+// Otros codecs se ignoran.
+func (m *Manager) setupVideoReceiver(call *Call, track *webrtc.TrackRemote) {
+	mime := track.Codec().MimeType
+
+	if err := m.EnsureRecordingDir(); err != nil {
+		call.log.Error("Error preparando grabación de video", "err", err)
+		return
+	}
+
+	var (
+		writer media.Writer
+		path   string
+		err    error
+	)
+	switch {
+	case strings.EqualFold(mime, webrtc.MimeTypeVP8):
+		path = m.recordingPath(fmt.Sprintf("video-%s.ivf", call.ID))
+		writer, err = ivfwriter.New(path)
+	case strings.EqualFold(mime, webrtc.MimeTypeH264):
+		path = m.recordingPath(fmt.Sprintf("video-%s.h264", call.ID))
+		writer, err = h264writer.New(path)
+	default:
+		call.log.Warn("Video entrante con codec no soportado para grabar", "codec", mime)
+		return
+	}
+	if err != nil {
+		call.log.Error("Error creando archivo de video", "err", err)
+		return
+	}
+	call.log.Info("Video entrante detectado", "path", path, "codec", mime)
+
+	meta := newRecordingMeta(call, track, path)
+	if err := meta.write(); err != nil {
+		call.log.Error("Error escribiendo metadata de grabación", "err", err)
+	}
+	// Cerrar el archivo antes de completar el sidecar y avisar OnRecording
+	defer func() {
+		if err := writer.Close(); err != nil {
+			call.log.Error("Error cerrando archivo de video", "err", err)
+		}
+		call.recordingDone(meta)
+	}()
+
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			call.log.Info("Fin de track de video", "err", err)
+			return
+		}
+		if writeErr := writer.WriteRTP(pkt); writeErr != nil {
+			call.log.Error("Error escribiendo video", "err", writeErr)
+			return
+		}
+	}
+}
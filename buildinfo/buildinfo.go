@@ -0,0 +1,26 @@
+// Package buildinfo guarda la versión del binario. Los valores se inyectan al
+// compilar:
+//
+//	go build -ldflags "-X webrtc-audio-server/buildinfo.Version=1.2.0 \
+//	  -X webrtc-audio-server/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X webrtc-audio-server/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+// Sin -ldflags quedan en "dev"
+var (
+	Version = "dev"
+	Commit  = "dev"
+	Date    = "dev"
+)
+
+// Info es la versión del binario tal como se expone por HTTP
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"build_date"`
+}
+
+// BuildInfo devuelve los datos de compilación
+func BuildInfo() Info {
+	return Info{Version: Version, Commit: Commit, Date: Date}
+}
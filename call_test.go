@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"webrtc-audio-server/audiocore"
+)
+
+// webhookRecorder levanta un endpoint de webhooks y junta los eventos. Con
+// block no responde hasta que se cierre (un endpoint trabado).
+type webhookRecorder struct {
+	mu     sync.Mutex
+	events []webhookEvent
+	block  chan struct{}
+}
+
+func (wr *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wr.block != nil {
+		<-wr.block
+	}
+	var ev webhookEvent
+	if err := json.NewDecoder(r.Body).Decode(&ev); err == nil {
+		wr.mu.Lock()
+		wr.events = append(wr.events, ev)
+		wr.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// names espera las entregas en curso (cierra webhooks) y devuelve los
+// eventos recibidos
+func (wr *webhookRecorder) names() []string {
+	_ = webhooks.wait(context.Background()) // sin plazo: no falla
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	var out []string
+	for _, ev := range wr.events {
+		out = append(out, ev.Event)
+	}
+	return out
+}
+
+// withTestWebhooks apunta los webhooks a un servidor de prueba
+func withTestWebhooks(t *testing.T) *webhookRecorder {
+	t.Helper()
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	prev := webhooks
+	webhooks = &webhookNotifier{urls: []string{srv.URL}, attempts: 1, client: srv.Client()}
+	t.Cleanup(func() { webhooks = prev })
+	return rec
+}
+
+// withCallManager arma un core nuevo desde el entorno actual del test
+func withCallManager(t *testing.T) {
+	t.Helper()
+	prev := core
+	core = newCallManager()
+	t.Cleanup(func() { core = prev })
+}
+
+// withCallLimit fija MAX_CONCURRENT_CALLS para el test
+func withCallLimit(t *testing.T, n int) {
+	t.Helper()
+	t.Setenv("MAX_CONCURRENT_CALLS", strconv.Itoa(n))
+	withCallManager(t)
+}
+
+// newTestCall registra en core una llamada sin negociar ni emisión de audio
+func newTestCall(t *testing.T) *audiocore.Call {
+	t.Helper()
+	call, err := core.CreateCall(audiocore.CallOptions{RemoteAddr: "192.0.2.1:5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { core.CloseCall(call) })
+	return call
+}
+
+// Los eventos de core salen como webhooks
+func TestCallEventsReachWebhooks(t *testing.T) {
+	rec := withTestWebhooks(t)
+	call := newTestCall(t)
+	call.MarkStarted(nil)
+	core.CloseCall(call)
+
+	got := rec.names()
+	if len(got) != 2 {
+		t.Fatalf("eventos = %v, want started y ended", got)
+	}
+	// el orden de entrega no está garantizado
+	seen := map[string]bool{}
+	for _, e := range got {
+		seen[e] = true
+	}
+	for _, e := range []string{audiocore.EventCallStarted, audiocore.EventCallEnded} {
+		if !seen[e] {
+			t.Errorf("falta %s en %v", e, got)
+		}
+	}
+}
+
+// Con el tope alcanzado /sdp responde 503 con Retry-After
+func TestHandleSDPCallLimit(t *testing.T) {
+	withCallLimit(t, 1)
+	newTestCall(t) // ocupa el único lugar
+
+	body := `{"offer":{"type":"offer","sdp":"v=0\r\n"}}`
+	rec := postSDP(t, body, "application/json")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503 (%s)", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Retry-After"); got != strconv.Itoa(callSlotRetryAfterSec) {
+		t.Errorf("Retry-After = %q, want %d", got, callSlotRetryAfterSec)
+	}
+	if code := errorCode(t, rec); code != ErrCallLimitReached.Code {
+		t.Errorf("code = %q", code)
+	}
+	if n := core.ActiveCalls(); n != 1 {
+		t.Errorf("ActiveCalls() = %d, want 1: la rechazada no debe ocupar lugar", n)
+	}
+}
+
+// Con N /sdp en paralelo se aceptan exactamente MAX_CONCURRENT_CALLS y el
+// resto recibe 503 con Retry-After
+func TestHandleSDPConcurrentCallLimit(t *testing.T) {
+	const maxCalls, requests = 2, 6
+	withTestWebhooks(t)
+	withCallLimit(t, maxCalls)
+	t.Cleanup(func() {
+		for _, call := range core.Calls() {
+			core.CloseCall(call)
+		}
+	})
+
+	offers := make([]string, requests)
+	for i := range offers {
+		_, offers[i] = newClientOffer(t)
+	}
+
+	recs := make([]*httptest.ResponseRecorder, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range offers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			recs[i] = postSDP(t, offers[i], "application/json")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	accepted := 0
+	for i, rec := range recs {
+		switch rec.Code {
+		case http.StatusOK:
+			accepted++
+		case http.StatusServiceUnavailable:
+			if got := rec.Header().Get("Retry-After"); got != strconv.Itoa(callSlotRetryAfterSec) {
+				t.Errorf("request %d: Retry-After = %q, want %d", i, got, callSlotRetryAfterSec)
+			}
+			if code := errorCode(t, rec); code != ErrCallLimitReached.Code {
+				t.Errorf("request %d: code = %q, want %q", i, code, ErrCallLimitReached.Code)
+			}
+		default:
+			t.Errorf("request %d: status = %d (%s)", i, rec.Code, rec.Body)
+		}
+	}
+	if accepted != maxCalls {
+		t.Errorf("aceptadas = %d, want %d", accepted, maxCalls)
+	}
+	if n := core.ActiveCalls(); n != maxCalls {
+		t.Errorf("ActiveCalls() = %d, want %d", n, maxCalls)
+	}
+}
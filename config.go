@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"webrtc-audio-server/audiocore"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Configuración WebRTC =========================
+
+// STUN por defecto si no se define STUN_URLS
+var defaultSTUNURLs = []string{
+	"stun:stun.l.google.com:19302",
+	"stun:stun.l.google.com:19305",
+}
+
+// Se arma al arrancar desde el entorno (ver buildICEConfig)
+var rtcConfig = buildICEConfig()
+
+// buildICEConfig arma la configuración ICE a partir de:
+//   - STUN_URLS: lista separada por comas (default: STUN de Google)
+//   - TURN_URL: uno o varios TURN separados por comas (para NATs estrictos)
+//   - TURN_USERNAME / TURN_CREDENTIAL: credenciales del TURN
+//
+// Sin variables definidas queda igual que la configuración original.
+func buildICEConfig() webrtc.Configuration {
+	stun := splitList(os.Getenv("STUN_URLS"))
+	if len(stun) == 0 {
+		stun = defaultSTUNURLs
+	}
+	servers := []webrtc.ICEServer{{URLs: stun}}
+
+	if turn := splitList(os.Getenv("TURN_URL")); len(turn) > 0 {
+		servers = append(servers, webrtc.ICEServer{
+			URLs:       turn,
+			Username:   os.Getenv("TURN_USERNAME"),
+			Credential: os.Getenv("TURN_CREDENTIAL"),
+		})
+	}
+
+	return webrtc.Configuration{ICEServers: servers}
+}
+
+// splitList separa "a, b,c" en ["a" "b" "c"] ignorando vacíos
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Autocolgado por inactividad RTP (0 = deshabilitado). Se puede cambiar por
+// llamada con ?idleSeconds= o el campo JSON idleSeconds.
+const IdleHangupSeconds = 0
+
+// resolveIdleTimeout elige el autocolgado de la llamada: campo JSON,
+// luego query param y por último IdleHangupSeconds
+func resolveIdleTimeout(query string, field *int) (time.Duration, error) {
+	secs := IdleHangupSeconds
+	switch {
+	case field != nil:
+		secs = *field
+	case query != "":
+		n, err := strconv.Atoi(query)
+		if err != nil {
+			return 0, fmt.Errorf("idleSeconds inválido: %q", query)
+		}
+		secs = n
+	}
+	if secs < 0 {
+		return 0, fmt.Errorf("idleSeconds no puede ser negativo: %d", secs)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// Trickle ICE por defecto (env TRICKLE_ICE=1). Cada request lo puede
+// forzar con ?trickle=1 o volver al modo bloqueante con ?trickle=0.
+func resolveTrickle(query string) (bool, error) {
+	switch query {
+	case "":
+		return os.Getenv("TRICKLE_ICE") == "1", nil
+	case "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("trickle inválido: %q", query)
+}
+
+// Captura del RTP entrante a rtp-<id>.pcap (env CAPTURE_RTP=1). Cada request
+// la puede forzar con ?captureRtp=1 o apagar con ?captureRtp=0.
+func resolveCaptureRTP(query string) (bool, error) {
+	switch query {
+	case "":
+		return os.Getenv("CAPTURE_RTP") == "1", nil
+	case "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("captureRtp inválido: %q", query)
+}
+
+// Duración máxima de una llamada desde que conecta (env MAX_CALL_SECONDS,
+// 0 = sin tope). Al vencer se cuelga y se emite call_max_duration.
+const MaxCallSeconds = 0
+
+func maxCallDuration() time.Duration {
+	return time.Duration(envInt("MAX_CALL_SECONDS", MaxCallSeconds)) * time.Second
+}
+
+// ========================= Emisión de OGG =========================
+
+// Ruta por defecto del OGG a emitir. Se puede sobreescribir por request
+// (campo JSON outOggPath o header X-Out-OGG, solo dentro de OutOGGDir) o con
+// la variable de entorno AUDIO_OUT_OGG.
+const OutOGGPath = "/home/desarrollo2/GolandProjects/webrtc-audio-server/audio-1755881306.ogg"
+
+const OutTimeoutSec = 25     // 0 = sin timeout; >0 segundos para cortar el envío
+const CloseOnTimeout = false // true: cierra la llamada al expirar el timeout
+
+// Veces que se reproduce el OGG saliente (env AUDIO_OUT_LOOP): 1 = una vez,
+// N = N veces, -1 = infinito (música de espera). OutTimeoutSec sigue
+// cortando el total.
+const OutLoop = 1
+
+func outLoop() int { return envInt("AUDIO_OUT_LOOP", OutLoop) }
+
+// Silencio Opus en la pista saliente mientras no se emite audio (antes de
+// empezar, en pausa y al terminar el OGG, o toda la llamada si no hay OGG),
+// para que el otro extremo no tome la línea por muerta. Env COMFORT_NOISE=1;
+// la llamada se negocia sendrecv aunque no haya OGG saliente.
+const ComfortNoise = false
+
+func comfortNoiseEnabled() bool {
+	if v := os.Getenv("COMFORT_NOISE"); v != "" {
+		return v == "1"
+	}
+	return ComfortNoise
+}
+
+// Header HTTP para elegir el OGG saliente por llamada
+const OutOGGHeader = "X-Out-OGG"
+
+// Directorio del que se pueden elegir OGG por request (env AUDIO_OUT_DIR).
+// Los clientes no autenticados no pueden abrir nada fuera de él; AUDIO_OUT_OGG
+// y OutOGGPath los define el operador y no tienen esta restricción.
+const OutOGGDir = "audio"
+
+func outOGGDir() string {
+	if dir := os.Getenv("AUDIO_OUT_DIR"); dir != "" {
+		return dir
+	}
+	return OutOGGDir
+}
+
+var errOutOGGOutsideDir = errors.New("ruta fuera del directorio de OGG salientes")
+
+// resolveOutOGGPath elige el OGG a emitir: override del request (dentro de
+// outOGGDir), luego AUDIO_OUT_OGG y por último OutOGGPath. explicit indica si
+// la ruta la pidió alguien (request o env) y no es el valor compilado.
+func resolveOutOGGPath(override string) (path string, explicit bool, err error) {
+	if override != "" {
+		path, err = resolveRequestOGGPath(outOGGDir(), override)
+		return path, true, err
+	}
+	if env := os.Getenv("AUDIO_OUT_OGG"); env != "" {
+		return env, true, nil
+	}
+	return OutOGGPath, false, nil
+}
+
+// resolveRequestOGGPath resuelve name dentro de dir: las rutas relativas se
+// toman desde dir y cualquier ruta que termine fuera (con "..", absoluta o
+// por un symlink) se rechaza
+func resolveRequestOGGPath(dir, name string) (string, error) {
+	base, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if base, err = filepath.Abs(base); err != nil {
+		return "", err
+	}
+	p := name
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errOutOGGOutsideDir
+	}
+	return real, nil
+}
+
+// validateOGGPath verifica que el archivo exista y se pueda leer
+func validateOGGPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.IsDir() {
+		return fmt.Errorf("%s es un directorio", path)
+	}
+	return nil
+}
+
+// ========================= Rate limit de /sdp =========================
+
+// Solicitudes por segundo y ráfaga permitidas por IP en /sdp. Se cambian con
+// SDP_RATE_PER_SEC / SDP_RATE_BURST; SDP_RATE_PER_SEC=0 lo deshabilita.
+// TRUST_FORWARDED_FOR=N (cantidad de proxies propios delante del servidor)
+// toma la IP del cliente de X-Forwarded-For, N entradas desde la derecha.
+const SDPRatePerSec = 1.0
+const SDPRateBurst = 5
+
+// Cada cuánto se limpian las IPs sin actividad y tras cuánto se olvidan
+const rateLimitCleanupInterval = time.Minute
+const rateLimitIdleTTL = 10 * time.Minute
+
+func newSDPLimiter() *ipLimiter {
+	return newIPLimiter(
+		envFloat("SDP_RATE_PER_SEC", SDPRatePerSec),
+		envInt("SDP_RATE_BURST", SDPRateBurst),
+		envInt("TRUST_FORWARDED_FOR", 0),
+	)
+}
+
+// ========================= Codecs =========================
+
+// Perfil de codecs (env MEDIA_PROFILE: default u opus, ver
+// audiocore.NewMediaEngine). Se resuelve una vez al arrancar (ver mediaProfile)
+var callMediaProfile = mediaProfile()
+
+// mediaProfile lee MEDIA_PROFILE, MEDIA_VIDEO_CODEC (vp8 | h264) y
+// MEDIA_G711=1; valores inválidos se loguean y se usa el perfil default.
+// El perfil default ya incluye PCMU/PCMA. OPUS_FMTP (p.ej.
+// "useinbandfec=1;stereo=0;maxaveragebitrate=32000") aplica a los dos
+// perfiles y se valida al arrancar (ver audiocore.ValidateOpusFmtp).
+func mediaProfile() audiocore.MediaProfile {
+	p := audiocore.MediaProfile{
+		Name:  strings.ToLower(os.Getenv("MEDIA_PROFILE")),
+		Video: strings.ToLower(os.Getenv("MEDIA_VIDEO_CODEC")),
+		G711:  os.Getenv("MEDIA_G711") == "1",
+
+		OpusFmtp: strings.TrimSpace(os.Getenv("OPUS_FMTP")),
+	}
+	switch p.Name {
+	case "", audiocore.MediaProfileDefault:
+		return audiocore.MediaProfile{Name: audiocore.MediaProfileDefault, OpusFmtp: p.OpusFmtp}
+	case audiocore.MediaProfileOpus:
+		if p.Video != "" && p.Video != "vp8" && p.Video != "h264" {
+			logger.Warn("MEDIA_VIDEO_CODEC inválido, sin video", "value", p.Video)
+			p.Video = ""
+		}
+		return p
+	}
+	logger.Warn("MEDIA_PROFILE inválido, usando default", "value", p.Name)
+	return audiocore.MediaProfile{Name: audiocore.MediaProfileDefault, OpusFmtp: p.OpusFmtp}
+}
+
+// ========================= Grabaciones =========================
+
+// Directorio de las grabaciones (env RECORDING_DIR), relativo al directorio
+// de trabajo si no es absoluto. Se crea si no existe.
+const RecordingDir = audiocore.DefaultRecordingDir
+
+func recordingDir() string {
+	if dir := os.Getenv("RECORDING_DIR"); dir != "" {
+		return dir
+	}
+	return RecordingDir
+}
+
+// Topes por archivo de audio entrante; al superarlos se abre un segmento
+// nuevo (audio-<id>-002.ogg, ...). 0 = sin tope. Env RECORDING_MAX_SECONDS
+// y RECORDING_MAX_BYTES.
+const RecordingMaxSeconds = 0
+const RecordingMaxBytes = 0
+
+func recordingMaxDuration() time.Duration {
+	return time.Duration(envInt("RECORDING_MAX_SECONDS", RecordingMaxSeconds)) * time.Second
+}
+
+func recordingMaxBytes() int64 { return int64(envInt("RECORDING_MAX_BYTES", RecordingMaxBytes)) }
+
+// ========================= Subida a S3 =========================
+
+// Subida de las grabaciones terminadas a un bucket S3-compatible (ver s3.go):
+//   - S3_BUCKET: bucket destino (vacío = deshabilitado)
+//   - S3_ENDPOINT: p.ej. http://minio:9000 (default AWS según S3_REGION)
+//   - S3_REGION: región para la firma SigV4
+//   - S3_PREFIX: prefijo de las claves, p.ej. "grabaciones/"
+//   - AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN
+//   - S3_DELETE_LOCAL=1: borra la copia local después de subirla
+//   - S3_ATTEMPTS / S3_TIMEOUT_SECONDS: intentos y timeout por intento
+const S3Region = "us-east-1"
+const S3Attempts = 3
+const S3TimeoutSec = 300
+
+// Espera antes del primer reintento; se duplica en cada intento
+const s3Backoff = 2 * time.Second
+
+func newS3Uploader() *s3Uploader {
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = S3Region
+	}
+	endpoint := strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	attempts := envInt("S3_ATTEMPTS", S3Attempts)
+	if attempts < 1 {
+		attempts = 1
+	}
+	timeout := time.Duration(envInt("S3_TIMEOUT_SECONDS", S3TimeoutSec)) * time.Second
+	return &s3Uploader{
+		endpoint:    endpoint,
+		bucket:      os.Getenv("S3_BUCKET"),
+		region:      region,
+		prefix:      os.Getenv("S3_PREFIX"),
+		accessKey:   os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:   os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:       os.Getenv("AWS_SESSION_TOKEN"),
+		deleteLocal: os.Getenv("S3_DELETE_LOCAL") == "1",
+		attempts:    attempts,
+		backoff:     s3Backoff,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// ========================= Video =========================
+
+// Grabar el video entrante (env RECORD_VIDEO=1). Sin esto, si la oferta trae
+// video se negocia recvonly pero no se crea ningún archivo.
+const RecordVideo = false
+
+func recordVideoEnabled() bool {
+	if v := os.Getenv("RECORD_VIDEO"); v != "" {
+		return v == "1"
+	}
+	return RecordVideo
+}
+
+// ========================= Límite de llamadas =========================
+
+// Máximo de llamadas simultáneas (env MAX_CONCURRENT_CALLS, 0 = sin límite)
+const MaxConcurrentCalls = 0
+
+// Retry-After sugerido cuando se alcanza el límite
+const callSlotRetryAfterSec = 5
+
+// ========================= Webhooks =========================
+
+// Webhooks de ciclo de vida (ver webhook.go):
+//   - WEBHOOK_URL: una o varias URLs separadas por comas (vacío = deshabilitado)
+//   - WEBHOOK_SECRET: si está, cada POST lleva la firma HMAC-SHA256 del body
+//   - WEBHOOK_TIMEOUT_SECONDS / WEBHOOK_ATTEMPTS: timeout por intento e intentos
+const WebhookTimeoutSec = 5
+const WebhookAttempts = 3
+
+// Espera antes del primer reintento; se duplica en cada intento
+const webhookBackoff = 500 * time.Millisecond
+
+func newWebhookNotifier() *webhookNotifier {
+	attempts := envInt("WEBHOOK_ATTEMPTS", WebhookAttempts)
+	if attempts < 1 {
+		attempts = 1
+	}
+	timeout := time.Duration(envInt("WEBHOOK_TIMEOUT_SECONDS", WebhookTimeoutSec)) * time.Second
+	return &webhookNotifier{
+		urls:     splitList(os.Getenv("WEBHOOK_URL")),
+		secret:   []byte(os.Getenv("WEBHOOK_SECRET")),
+		attempts: attempts,
+		backoff:  webhookBackoff,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// ========================= Llamadas =========================
+
+// core crea y registra las llamadas; los handlers HTTP son una capa encima
+// (ver audiocore.Manager). Se arma al arrancar desde el entorno.
+var core = newCallManager()
+
+func newCallManager() *audiocore.Manager {
+	return audiocore.NewManager(audiocore.Config{
+		Logger: logger,
+		RTC:    rtcConfig,
+		Media:  callMediaProfile,
+		// transport se carga en main, antes de aceptar llamadas
+		SettingEngine: func() (webrtc.SettingEngine, error) { return transport.settingEngine() },
+
+		MaxCalls:        envInt("MAX_CONCURRENT_CALLS", MaxConcurrentCalls),
+		MaxCallDuration: maxCallDuration(),
+
+		RecordingDir:         recordingDir(),
+		RecordingMaxDuration: recordingMaxDuration(),
+		RecordingMaxBytes:    recordingMaxBytes(),
+		RecordVideo:          recordVideoEnabled(),
+
+		ComfortNoise:   comfortNoiseEnabled(),
+		OutLoop:        outLoop(),
+		OutTimeout:     time.Duration(OutTimeoutSec) * time.Second,
+		CloseOnTimeout: CloseOnTimeout,
+
+		OnEvent: func(event, callID string, data any) { webhooks.notify(event, callID, data) },
+		OnRecording: func(call *audiocore.Call, rec *audiocore.RecordingMeta) {
+			uploads.upload(call.Log(), rec.CallID, rec.StartedAt, rec.Path(), rec.SidecarPath())
+		},
+	})
+}
+
+// ========================= Servidor HTTP =========================
+
+// Dirección de escucha por defecto; se cambia con LISTEN_ADDR o -addr
+// (p.ej. "127.0.0.1:8080" para escuchar solo en loopback)
+const ListenAddr = ":8080"
+
+func listenAddr() string {
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	return ListenAddr
+}
+
+// ========================= Límites HTTP =========================
+
+// Tamaño máximo del body (env MAX_BODY_BYTES y MAX_SDP_BODY_BYTES para /sdp
+// e /ice-restart). Si se excede se responde 413.
+const MaxBodyBytes = 1 << 20
+const MaxSDPBodyBytes = 4 << 20
+
+// ========================= Apagado =========================
+
+// Tiempo máximo para colgar llamadas al apagar (env SHUTDOWN_GRACE_SECONDS)
+const ShutdownGraceSeconds = 10
+
+func shutdownGrace() time.Duration {
+	return time.Duration(envInt("SHUTDOWN_GRACE_SECONDS", ShutdownGraceSeconds)) * time.Second
+}
+
+// envInt lee un entero del entorno; si falta o es inválido usa def
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		logger.Warn("Variable de entorno inválida, usando default", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return n
+}
+
+// envFloat lee un float del entorno; si falta o es inválido usa def
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		logger.Warn("Variable de entorno inválida, usando default", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestResolveRequestOGGPath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "audio")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"audio/a.ogg", "audio/sub/b.ogg", "secreto.ogg"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secreto.ogg"), filepath.Join(dir, "link.ogg")); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := []struct{ name, want string }{
+		{"a.ogg", "audio/a.ogg"},
+		{"sub/b.ogg", "audio/sub/b.ogg"},
+		{"sub/../a.ogg", "audio/a.ogg"},
+		{filepath.Join(dir, "a.ogg"), "audio/a.ogg"},
+	}
+	for _, tc := range ok {
+		got, err := resolveRequestOGGPath(dir, tc.name)
+		if err != nil {
+			t.Errorf("%q: error inesperado %v", tc.name, err)
+			continue
+		}
+		want, _ := filepath.EvalSymlinks(filepath.Join(root, tc.want))
+		if got != want {
+			t.Errorf("%q = %q, want %q", tc.name, got, want)
+		}
+	}
+
+	outside := []string{
+		"../secreto.ogg",
+		filepath.Join(root, "secreto.ogg"),
+		"link.ogg",
+	}
+	for _, name := range outside {
+		if _, err := resolveRequestOGGPath(dir, name); err != errOutOGGOutsideDir {
+			t.Errorf("%q: err = %v, want errOutOGGOutsideDir", name, err)
+		}
+	}
+
+	if _, err := resolveRequestOGGPath(dir, "no-existe.ogg"); err == nil {
+		t.Error("un archivo inexistente debería fallar")
+	}
+}
+
+// El 400 no debe revelar si la ruta pedida existe o no
+func TestHandleSDPOutOGGGenericError(t *testing.T) {
+	t.Setenv("AUDIO_OUT_DIR", t.TempDir())
+
+	offer := `{"offer":{"type":"offer","sdp":"v=0\r\n"},"outOggPath":%q}`
+	var bodies []string
+	for _, path := range []string{"/etc/passwd", "/no/existe.ogg", "../../etc/shadow"} {
+		rec := postSDP(t, fmt.Sprintf(offer, path), "application/json")
+		if rec.Code != ErrOutOGGUnavailable.HTTPStatus {
+			t.Fatalf("%s: status = %d, want %d", path, rec.Code, ErrOutOGGUnavailable.HTTPStatus)
+		}
+		bodies = append(bodies, rec.Body.String())
+	}
+	for _, b := range bodies[1:] {
+		if b != bodies[0] {
+			t.Errorf("respuestas distintas según la ruta: %q vs %q", bodies[0], b)
+		}
+	}
+}
+
+func TestBuildICEConfig(t *testing.T) {
+	googleSTUN := webrtc.ICEServer{URLs: defaultSTUNURLs}
+	cases := []struct {
+		name string
+		env  map[string]string
+		want []webrtc.ICEServer
+	}{
+		{"sin variables", nil, []webrtc.ICEServer{googleSTUN}},
+		{"STUN propio", map[string]string{"STUN_URLS": "stun:a:3478, stun:b:3478,"},
+			[]webrtc.ICEServer{{URLs: []string{"stun:a:3478", "stun:b:3478"}}}},
+		{"TURN con credenciales", map[string]string{
+			"TURN_URL": "turn:t:3478?transport=udp,turns:t:5349", "TURN_USERNAME": "u", "TURN_CREDENTIAL": "p",
+		}, []webrtc.ICEServer{googleSTUN, {
+			URLs: []string{"turn:t:3478?transport=udp", "turns:t:5349"}, Username: "u", Credential: "p",
+		}}},
+		{"TURN sin credenciales", map[string]string{"TURN_URL": "turn:t:3478"},
+			[]webrtc.ICEServer{googleSTUN, {URLs: []string{"turn:t:3478"}, Credential: ""}}},
+		{"credenciales sin TURN_URL se ignoran", map[string]string{"TURN_USERNAME": "u", "TURN_CREDENTIAL": "p"},
+			[]webrtc.ICEServer{googleSTUN}},
+		{"STUN y TURN", map[string]string{"STUN_URLS": "stun:a:3478", "TURN_URL": "turn:t:3478", "TURN_USERNAME": "u", "TURN_CREDENTIAL": "p"},
+			[]webrtc.ICEServer{{URLs: []string{"stun:a:3478"}}, {URLs: []string{"turn:t:3478"}, Username: "u", Credential: "p"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"STUN_URLS", "TURN_URL", "TURN_USERNAME", "TURN_CREDENTIAL"} {
+				t.Setenv(name, tc.env[name])
+			}
+			got := buildICEConfig().ICEServers
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ICEServers = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ========================= Errores de la API =========================
+
+// APIError es un error de la API con código estable para los clientes. Se
+// responde como JSON {"error": "...", "code": "..."}.
+type APIError struct {
+	Code       string
+	Message    string
+	HTTPStatus int
+}
+
+func (e *APIError) Error() string { return e.Code + ": " + e.Message }
+
+// Catálogo de errores; Message es el texto por defecto
+var (
+	ErrMethodNotAllowed    = &APIError{"METHOD_NOT_ALLOWED", "método no permitido", http.StatusMethodNotAllowed}
+	ErrInvalidRequest      = &APIError{"INVALID_REQUEST", "request inválido", http.StatusBadRequest}
+	ErrMissingCallID       = &APIError{"MISSING_CALL_ID", "falta query param id", http.StatusBadRequest}
+	ErrCallNotFound        = &APIError{"CALL_NOT_FOUND", "call id no encontrado", http.StatusNotFound}
+	ErrInvalidSDP          = &APIError{"INVALID_SDP", "SDP inválido", http.StatusBadRequest}
+	ErrInvalidCandidate    = &APIError{"INVALID_CANDIDATE", "candidato ICE inválido", http.StatusBadRequest}
+	ErrOutOGGUnavailable   = &APIError{"OUT_OGG_UNAVAILABLE", "OGG saliente no disponible", http.StatusBadRequest}
+	ErrNegotiationConflict = &APIError{"NEGOTIATION_CONFLICT", "negociación en curso", http.StatusConflict}
+	ErrPlaybackUnavailable = &APIError{"PLAYBACK_UNAVAILABLE", "no hay emisión de audio", http.StatusConflict}
+	ErrInvalidRecording    = &APIError{"INVALID_RECORDING_NAME", "nombre de grabación inválido", http.StatusBadRequest}
+	ErrRecordingNotFound   = &APIError{"RECORDING_NOT_FOUND", "grabación no encontrada", http.StatusNotFound}
+	ErrPayloadTooLarge     = &APIError{"PAYLOAD_TOO_LARGE", "cuerpo demasiado grande", http.StatusRequestEntityTooLarge}
+	ErrRateLimited         = &APIError{"RATE_LIMITED", "demasiadas solicitudes", http.StatusTooManyRequests}
+	ErrCallLimitReached    = &APIError{"CALL_LIMIT_REACHED", "límite de llamadas concurrentes alcanzado", http.StatusServiceUnavailable}
+	ErrServerDraining      = &APIError{"SERVER_DRAINING", "servidor apagándose, no se aceptan llamadas nuevas", http.StatusServiceUnavailable}
+	ErrInternal            = &APIError{"INTERNAL_ERROR", "error interno", http.StatusInternalServerError}
+)
+
+// writeError responde e como JSON. detail, si no es vacío, reemplaza el
+// mensaje por defecto.
+func writeError(w http.ResponseWriter, e *APIError, detail string) {
+	msg := e.Message
+	if detail != "" {
+		msg = detail
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error": msg,
+		"code":  e.Code,
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, ErrCallNotFound, "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["code"] != "CALL_NOT_FOUND" || body["error"] != ErrCallNotFound.Message {
+		t.Errorf("body = %v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	writeError(rec, ErrInvalidSDP, "falta offer.sdp")
+	_ = json.NewDecoder(rec.Body).Decode(&body)
+	if body["code"] != "INVALID_SDP" || body["error"] != "falta offer.sdp" {
+		t.Errorf("con detalle: body = %v", body)
+	}
+}
+
+// Códigos concretos para errores conocidos de cada handler
+func TestHandlerErrorCodes(t *testing.T) {
+	withTestWebhooks(t)
+	call := newTestCall(t) // sin emisión de audio
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		want    *APIError
+	}{
+		{"/sdp con GET", handleSDP, http.MethodGet, "/sdp", ErrMethodNotAllowed},
+		{"/hangup sin id", handleHangup, http.MethodGet, "/hangup", ErrMissingCallID},
+		{"/hangup id desconocido", handleHangup, http.MethodGet, "/hangup?id=nope", ErrCallNotFound},
+		{"/status id desconocido", handleStatus, http.MethodGet, "/status?id=nope", ErrCallNotFound},
+		{"/ice-candidate sin id", handleICECandidate, http.MethodGet, "/ice-candidate", ErrMissingCallID},
+		{"/ice-candidate since inválido", handleICECandidate, http.MethodGet, "/ice-candidate?id=" + call.ID + "&since=-1", ErrInvalidRequest},
+		{"/ice-candidate con PUT", handleICECandidate, http.MethodPut, "/ice-candidate?id=" + call.ID, ErrMethodNotAllowed},
+		{"/ice-restart con GET", handleICERestart, http.MethodGet, "/ice-restart?id=" + call.ID, ErrMethodNotAllowed},
+		{"/ice-restart id desconocido", handleICERestart, http.MethodPost, "/ice-restart?id=nope", ErrCallNotFound},
+		{"/playback-control con GET", handlePlaybackControl, http.MethodGet, "/playback-control?id=" + call.ID + "&action=pause", ErrMethodNotAllowed},
+		{"/playback-control action inválida", handlePlaybackControl, http.MethodPost, "/playback-control?id=" + call.ID + "&action=stop", ErrInvalidRequest},
+		{"/playback-control sin emisión", handlePlaybackControl, http.MethodPost, "/playback-control?id=" + call.ID + "&action=pause", ErrPlaybackUnavailable},
+		{"/recordings con POST", handleRecordings, http.MethodPost, "/recordings", ErrMethodNotAllowed},
+		{"/recordings nombre inválido", handleRecordings, http.MethodGet, "/recordings/notas.txt", ErrInvalidRecording},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.want.HTTPStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want.HTTPStatus)
+			}
+			if code := errorCode(t, rec); code != tc.want.Code {
+				t.Errorf("code = %q, want %q", code, tc.want.Code)
+			}
+		})
+	}
+}
+
+func TestHandleSDPDraining(t *testing.T) {
+	draining.Store(true)
+	defer draining.Store(false)
+
+	rec := postSDP(t, `{"offer":{"type":"offer","sdp":"v=0\r\n"}}`, "application/json")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if code := errorCode(t, rec); code != ErrServerDraining.Code {
+		t.Errorf("code = %q", code)
+	}
+}
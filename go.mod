@@ -2,7 +2,11 @@ module webrtc-audio-server
 
 go 1.22
 
-require github.com/pion/webrtc/v3 v3.2.43
+require (
+	github.com/pion/rtcp v1.2.12
+	github.com/pion/rtp v1.8.5
+	github.com/pion/webrtc/v3 v3.2.43
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -14,8 +18,6 @@ require (
 	github.com/pion/logging v0.2.2 // indirect
 	github.com/pion/mdns v0.0.12 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
-	github.com/pion/rtcp v1.2.12 // indirect
-	github.com/pion/rtp v1.8.5 // indirect
 	github.com/pion/sctp v1.8.16 // indirect
 	github.com/pion/sdp/v3 v3.0.9 // indirect
 	github.com/pion/srtp/v2 v2.0.18 // indirect
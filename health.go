@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"webrtc-audio-server/buildinfo"
+)
+
+// ========================= Health / versión =========================
+
+// handleHealth responde 200 mientras el servidor acepta llamadas y 503
+// durante el apagado, para que el balanceador deje de mandar tráfico
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, code := "ok", http.StatusOK
+	if draining.Load() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":       status,
+		"version":      buildinfo.BuildInfo(),
+		"active_calls": core.ActiveCalls(),
+	})
+}
+
+// handleVersion devuelve versión, commit y fecha de compilación
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(buildinfo.BuildInfo())
+}
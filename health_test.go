@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"webrtc-audio-server/buildinfo"
+)
+
+// withBuildInfo fija los datos de compilación para el test
+func withBuildInfo(t *testing.T, version, commit, date string) {
+	t.Helper()
+	prev := buildinfo.BuildInfo()
+	buildinfo.Version, buildinfo.Commit, buildinfo.Date = version, commit, date
+	t.Cleanup(func() {
+		buildinfo.Version, buildinfo.Commit, buildinfo.Date = prev.Version, prev.Commit, prev.Date
+	})
+}
+
+// healthBody es la respuesta JSON de /health
+type healthBody struct {
+	Status      string         `json:"status"`
+	Version     buildinfo.Info `json:"version"`
+	ActiveCalls int            `json:"active_calls"`
+}
+
+func getHealth(t *testing.T) (int, healthBody) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body healthBody
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+// /health responde 200 ok con la versión y las llamadas activas, y 503
+// draining durante el apagado
+func TestHandleHealth(t *testing.T) {
+	withDrainState(t)
+	withBuildInfo(t, "1.2.0", "abc1234", "2026-01-02T03:04:05Z")
+	newTestCall(t)
+
+	code, body := getHealth(t)
+	if code != http.StatusOK || body.Status != "ok" {
+		t.Errorf("/health = %d %q, want 200 ok", code, body.Status)
+	}
+	want := buildinfo.Info{Version: "1.2.0", Commit: "abc1234", Date: "2026-01-02T03:04:05Z"}
+	if body.Version != want {
+		t.Errorf("version = %+v, want %+v", body.Version, want)
+	}
+	if body.ActiveCalls != 1 {
+		t.Errorf("active_calls = %d, want 1", body.ActiveCalls)
+	}
+
+	draining.Store(true)
+	code, body = getHealth(t)
+	if code != http.StatusServiceUnavailable || body.Status != "draining" {
+		t.Errorf("/health apagando = %d %q, want 503 draining", code, body.Status)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	withBuildInfo(t, "1.2.0", "abc1234", "2026-01-02T03:04:05Z")
+
+	rec := httptest.NewRecorder()
+	handleVersion(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"version": "1.2.0", "commit": "abc1234", "build_date": "2026-01-02T03:04:05Z"}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %q, want %q", k, body[k], v)
+		}
+	}
+	if len(body) != len(want) {
+		t.Errorf("campos = %v, want solo version, commit y build_date", body)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"webrtc-audio-server/audiocore"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Trickle ICE =========================
+
+// handleICECandidate intercambia candidatos de una llamada ya creada:
+//   - POST /ice-candidate?id=...: agrega candidatos remotos. Body JSON (un
+//     candidato o una lista) o, sin Content-Type JSON, la lista codificada
+//     como en /sdp.
+//   - GET /ice-candidate?id=...&since=N: devuelve los candidatos locales
+//     desde el índice N y si el gathering ya terminó.
+func handleICECandidate(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, ErrMissingCallID, "")
+		return
+	}
+	call, ok := core.Get(id)
+	if !ok {
+		writeError(w, ErrCallNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		since := 0
+		if raw := r.URL.Query().Get("since"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeError(w, ErrInvalidRequest, "since inválido")
+				return
+			}
+			since = n
+		}
+		candidates, done := call.LocalCandidates(since)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"candidates": candidates,
+			"next":       since + len(candidates),
+			"complete":   done,
+		})
+
+	case http.MethodPost:
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		candidates, err := parseRemoteCandidates(body, isJSONRequest(r))
+		if err != nil {
+			writeDecodeError(w, ErrInvalidCandidate, "candidatos inválidos: "+err.Error(), err)
+			return
+		}
+		for _, c := range candidates {
+			if err := call.PC.AddICECandidate(c); err != nil {
+				writeError(w, ErrInvalidCandidate, "AddICECandidate falló: "+err.Error())
+				return
+			}
+			call.Log().Debug("ICE candidate remoto (trickle) añadido", "candidate", c.Candidate)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, ErrMethodNotAllowed, "use GET o POST")
+	}
+}
+
+// parseRemoteCandidates acepta un candidato JSON, una lista JSON o la lista
+// codificada con signalEncode
+func parseRemoteCandidates(body []byte, asJSON bool) ([]webrtc.ICECandidateInit, error) {
+	var list []webrtc.ICECandidateInit
+	if !asJSON {
+		err := signalDecode(strings.TrimSpace(string(body)), &list)
+		return list, err
+	}
+	if err := json.Unmarshal(body, &list); err == nil {
+		return list, nil
+	}
+	var single webrtc.ICECandidateInit
+	if err := json.Unmarshal(body, &single); err != nil {
+		return nil, err
+	}
+	return []webrtc.ICECandidateInit{single}, nil
+}
+
+// ========================= ICE restart =========================
+
+// handleICERestart renegocia ICE de una llamada trabada, en dos pasos:
+//   - POST /ice-restart?id=... sin body: el servidor crea una oferta con
+//     ICERestart y la devuelve (JSON {offer, candidates, callId, next} o el
+//     formato legacy de /sdp). ?trickle=1 responde sin esperar el gathering;
+//     el resto de candidatos se lee con GET /ice-candidate?since=next.
+//   - POST /ice-restart?id=... con la answer del cliente (mismos formatos que
+//     /sdp, candidatos opcionales): se aplica y la llamada sigue con ICE nuevo.
+func handleICERestart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed, "solo POST")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, ErrMissingCallID, "")
+		return
+	}
+	call, ok := core.Get(id)
+	if !ok {
+		writeError(w, ErrCallNotFound, "")
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	asJSON := isJSONRequest(r)
+
+	if len(strings.TrimSpace(string(body))) > 0 {
+		applyRestartAnswer(w, call, body, asJSON)
+		return
+	}
+
+	trickle, err := resolveTrickle(r.URL.Query().Get("trickle"))
+	if err != nil {
+		writeError(w, ErrInvalidRequest, err.Error())
+		return
+	}
+	if st := call.PC.SignalingState(); st != webrtc.SignalingStateStable {
+		writeError(w, ErrNegotiationConflict, "negociación en curso: "+st.String())
+		return
+	}
+
+	call.Log().Info("ICE restart solicitado")
+	next := call.RestartGathering()
+	offer, err := call.PC.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		writeError(w, ErrInternal, "CreateOffer falló: "+err.Error())
+		return
+	}
+	// Después de CreateOffer: el restart reinicia el gatherer
+	gatherComplete := webrtc.GatheringCompletePromise(call.PC)
+	if err := call.PC.SetLocalDescription(offer); err != nil {
+		writeError(w, ErrInternal, "SetLocalDescription falló: "+err.Error())
+		return
+	}
+	if !trickle {
+		<-gatherComplete
+	}
+
+	candidates, _ := call.LocalCandidates(next)
+	local := *call.PC.LocalDescription()
+	writeSignalResponse(w, asJSON, call.ID, iceRestartResponse{
+		Offer:      local,
+		Candidates: candidates,
+		CallID:     call.ID,
+		Next:       next + len(candidates),
+	}, local, candidates)
+	call.Log().Info("Oferta de ICE restart enviada al cliente")
+}
+
+// applyRestartAnswer aplica la answer del cliente a la oferta de restart
+func applyRestartAnswer(w http.ResponseWriter, call *audiocore.Call, body []byte, asJSON bool) {
+	if st := call.PC.SignalingState(); st != webrtc.SignalingStateHaveLocalOffer {
+		writeError(w, ErrNegotiationConflict, "no hay un ICE restart pendiente")
+		return
+	}
+	answer, candidates, err := parseRemoteAnswer(body, asJSON)
+	if err != nil {
+		writeDecodeError(w, ErrInvalidSDP, err.Error(), err)
+		return
+	}
+	if err := call.PC.SetRemoteDescription(answer); err != nil {
+		writeError(w, ErrInvalidSDP, "SetRemoteDescription falló: "+err.Error())
+		return
+	}
+	for _, c := range candidates {
+		if err := call.PC.AddICECandidate(c); err != nil {
+			writeError(w, ErrInvalidCandidate, "AddICECandidate falló: "+err.Error())
+			return
+		}
+	}
+	call.Log().Info("ICE restart completado", "candidates", len(candidates))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// iceCredentials devuelve el primer a=ice-ufrag y a=ice-pwd de sdp
+func iceCredentials(sdp string) (ufrag, pwd string) {
+	for _, line := range strings.Split(sdp, "\r\n") {
+		if v, ok := strings.CutPrefix(line, "a=ice-ufrag:"); ok && ufrag == "" {
+			ufrag = v
+		}
+		if v, ok := strings.CutPrefix(line, "a=ice-pwd:"); ok && pwd == "" {
+			pwd = v
+		}
+	}
+	return ufrag, pwd
+}
+
+// postJSON manda body como JSON a handler (nil: body vacío)
+func postJSON(handler http.HandlerFunc, target string, body any) *httptest.ResponseRecorder {
+	var buf strings.Builder
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(buf.String()))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// Después de negociar, /ice-restart ofrece credenciales ICE nuevas y acepta
+// la answer del cliente
+func TestICERestartNewCredentials(t *testing.T) {
+	withTestWebhooks(t)
+	withCallManager(t)
+
+	client, body := newClientOffer(t)
+	rec := postSDP(t, body, "application/json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/sdp: status = %d: %s", rec.Code, rec.Body)
+	}
+	var negotiated sdpResponse
+	if err := json.NewDecoder(rec.Body).Decode(&negotiated); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetRemoteDescription(negotiated.Answer); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = postJSON(handleICERestart, "/ice-restart?id="+negotiated.CallID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/ice-restart: status = %d: %s", rec.Code, rec.Body)
+	}
+	var restart iceRestartResponse
+	if err := json.NewDecoder(rec.Body).Decode(&restart); err != nil {
+		t.Fatal(err)
+	}
+
+	oldUfrag, oldPwd := iceCredentials(negotiated.Answer.SDP)
+	newUfrag, newPwd := iceCredentials(restart.Offer.SDP)
+	if oldUfrag == "" || oldPwd == "" || newUfrag == "" || newPwd == "" {
+		t.Fatalf("faltan credenciales ICE: antes %q/%q, después %q/%q", oldUfrag, oldPwd, newUfrag, newPwd)
+	}
+	if newUfrag == oldUfrag {
+		t.Errorf("ice-ufrag no cambió: %q", newUfrag)
+	}
+	if newPwd == oldPwd {
+		t.Errorf("ice-pwd no cambió: %q", newPwd)
+	}
+
+	// El cliente contesta la oferta de restart y la llamada sigue
+	if err := client.SetRemoteDescription(restart.Offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := client.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	rec = postJSON(handleICERestart, "/ice-restart?id="+negotiated.CallID, map[string]any{"answer": answer})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("answer de restart: status = %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ========================= Logging =========================
+
+// logger es el logger del servidor. El nivel sale de LOG_LEVEL (debug, info,
+// warn, error; default info). Los logs de una llamada llevan call_id como
+// atributo (ver audiocore.Call.Log).
+var logger = newLogger(os.Getenv("LOG_LEVEL"))
+
+func newLogger(level string) *slog.Logger {
+	lv := slog.LevelInfo
+	switch strings.ToLower(level) {
+	case "debug":
+		lv = slog.LevelDebug
+	case "warn", "warning":
+		lv = slog.LevelWarn
+	case "error":
+		lv = slog.LevelError
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lv}))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// postSDP manda body a /sdp con los mismos middlewares que main
+func postSDP(t *testing.T, body, contentType string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/sdp", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	withCORS(withBodyLimit(http.HandlerFunc(handleSDP), MaxBodyBytes, MaxSDPBodyBytes)).ServeHTTP(rec, req)
+	return rec
+}
+
+// newClientOffer arma la oferta de audio de un cliente pion. Devuelve la
+// PeerConnection (para aplicarle la answer) y el body JSON para /sdp.
+func newClientOffer(t *testing.T) (*webrtc.PeerConnection, string) {
+	t.Helper()
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(sdpRequest{Offer: offer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, string(body)
+}
+
+// run atiende en el listener recibido y al cancelar ctx apaga y vuelve
+func TestRun(t *testing.T) {
+	t.Setenv("RECORDING_DIR", t.TempDir())
+	withDrainState(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, ln) }()
+
+	resp, err := http.Get(base + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health struct {
+		Status string `json:"status"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || health.Status != "ok" {
+		t.Fatalf("/health = %d %q, want 200 ok", resp.StatusCode, health.Status)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run no volvió al cancelar ctx")
+	}
+	if !draining.Load() {
+		t.Error("run volvió sin pasar por el apagado")
+	}
+	if _, err := http.Get(base + "/health"); err == nil {
+		t.Error("el servidor sigue atendiendo después de run")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"webrtc-audio-server/audiocore"
+)
+
+// Con OPUS_FMTP la answer ofrece solo Opus con esa línea fmtp, en los dos
+// perfiles
+func TestOpusFmtpInAnswer(t *testing.T) {
+	const fmtp = "useinbandfec=1;stereo=0;maxaveragebitrate=32000"
+	fmtpLine := regexp.MustCompile(`a=fmtp:\d+ ` + regexp.QuoteMeta(fmtp) + "\r\n")
+
+	for _, name := range []string{"DTLS_ROLE", "NETWORK_TYPES", "UDP_PORT_MIN", "UDP_PORT_MAX"} {
+		t.Setenv(name, "")
+	}
+	cfg, err := loadTransportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{audiocore.MediaProfileDefault, audiocore.MediaProfileOpus} {
+		t.Run(name, func(t *testing.T) {
+			sdp := negotiateAnswer(t, cfg, audiocore.MediaProfile{Name: name, OpusFmtp: fmtp})
+			if !fmtpLine.MatchString(sdp) {
+				t.Errorf("answer sin a=fmtp con %q:\n%s", fmtp, sdp)
+			}
+			if strings.Contains(sdp, "PCMU") || strings.Contains(sdp, "PCMA") {
+				t.Errorf("answer con codecs además de Opus:\n%s", sdp)
+			}
+		})
+	}
+}
+
+// audioSection devuelve los payload types de m=audio y sus a=rtpmap, en
+// orden
+func audioSection(sdp string) (payloadTypes, rtpmaps []string) {
+	inAudio := false
+	for _, line := range strings.Split(sdp, "\r\n") {
+		if strings.HasPrefix(line, "m=") {
+			inAudio = strings.HasPrefix(line, "m=audio ")
+			if fields := strings.Fields(line); inAudio && len(fields) > 3 {
+				payloadTypes = fields[3:]
+			}
+			continue
+		}
+		if inAudio && strings.HasPrefix(line, "a=rtpmap:") {
+			rtpmaps = append(rtpmaps, line)
+		}
+	}
+	return payloadTypes, rtpmaps
+}
+
+// El perfil opus negocia solo sus codecs, con sus payload types fijos
+func TestMediaProfilePayloadTypes(t *testing.T) {
+	for _, name := range []string{"DTLS_ROLE", "NETWORK_TYPES", "UDP_PORT_MIN", "UDP_PORT_MAX"} {
+		t.Setenv(name, "")
+	}
+	cfg, err := loadTransportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		profile audiocore.MediaProfile
+		pts     []string
+		rtpmaps []string
+	}{
+		{
+			"opus",
+			audiocore.MediaProfile{Name: audiocore.MediaProfileOpus},
+			[]string{"111"},
+			[]string{"a=rtpmap:111 opus/48000/2"},
+		},
+		{
+			"opus con G.711",
+			audiocore.MediaProfile{Name: audiocore.MediaProfileOpus, G711: true},
+			[]string{"111", "0", "8"},
+			[]string{"a=rtpmap:111 opus/48000/2", "a=rtpmap:0 PCMU/8000", "a=rtpmap:8 PCMA/8000"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdp := negotiateAnswer(t, cfg, tc.profile)
+			pts, rtpmaps := audioSection(sdp)
+			if !reflect.DeepEqual(pts, tc.pts) {
+				t.Errorf("m=audio payload types = %v, want %v", pts, tc.pts)
+			}
+			if !reflect.DeepEqual(rtpmaps, tc.rtpmaps) {
+				t.Errorf("a=rtpmap = %v, want %v\n%s", rtpmaps, tc.rtpmaps, sdp)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// ========================= Middlewares HTTP =========================
+
+// Origen permitido por CORS (env CORS_ALLOW_ORIGIN, default "*")
+const CORSAllowOrigin = "*"
+
+// withCORS agrega los headers CORS a todas las respuestas y contesta el
+// preflight OPTIONS con 204 sin llegar al handler
+func withCORS(h http.Handler) http.Handler {
+	origin := os.Getenv("CORS_ALLOW_ORIGIN")
+	if origin == "" {
+		origin = CORSAllowOrigin
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr := w.Header()
+		hdr.Set("Access-Control-Allow-Origin", origin)
+		if origin != "*" {
+			hdr.Add("Vary", "Origin")
+		}
+		hdr.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		hdr.Set("Access-Control-Allow-Headers", "Content-Type, "+OutOGGHeader)
+		hdr.Set("Access-Control-Expose-Headers", "X-Call-ID")
+
+		if r.Method == http.MethodOptions {
+			hdr.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withBodyLimit corta el body de cada request con http.MaxBytesReader: sdpMax
+// para los endpoints que reciben SDP y jsonMax para el resto. Si el
+// Content-Length ya lo excede responde 413 sin llegar al handler.
+func withBodyLimit(h http.Handler, jsonMax, sdpMax int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := jsonMax
+		switch r.URL.Path {
+		case "/sdp", "/ice-restart":
+			limit = sdpMax
+		}
+		if r.ContentLength > limit {
+			writeError(w, ErrPayloadTooLarge, "")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// readBody lee el body completo; si falla ya respondió (413 si superó el
+// límite de withBodyLimit, 400 si no) y devuelve false
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, ErrPayloadTooLarge, "")
+		} else {
+			writeError(w, ErrInvalidRequest, "error leyendo cuerpo")
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+// writeDecodeError responde 413 si err viene de una señal que excede
+// maxSignalBytes al descomprimirse y e con detail en cualquier otro caso
+func writeDecodeError(w http.ResponseWriter, e *APIError, detail string, err error) {
+	if errors.Is(err, errSignalTooLarge) {
+		writeError(w, ErrPayloadTooLarge, "")
+		return
+	}
+	writeError(w, e, detail)
+}
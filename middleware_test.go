@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoBody responde 204 si pudo leer el body con readBody
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readBody(w, r); ok {
+		w.WriteHeader(http.StatusNoContent)
+	}
+})
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("respuesta no es JSON: %v", err)
+	}
+	return body.Code
+}
+
+func TestWithBodyLimit(t *testing.T) {
+	h := withBodyLimit(echoBody, 16, 64)
+
+	cases := []struct {
+		name    string
+		path    string
+		size    int
+		chunked bool // sin Content-Length: lo corta MaxBytesReader
+		want    int
+	}{
+		{"json dentro del límite", "/hangup", 16, false, http.StatusNoContent},
+		{"json excedido", "/hangup", 17, false, http.StatusRequestEntityTooLarge},
+		{"json excedido sin Content-Length", "/hangup", 17, true, http.StatusRequestEntityTooLarge},
+		{"sdp usa el límite mayor", "/sdp", 64, false, http.StatusNoContent},
+		{"sdp excedido", "/sdp", 65, false, http.StatusRequestEntityTooLarge},
+		{"ice-restart excedido sin Content-Length", "/ice-restart", 65, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(strings.Repeat("a", tc.size))
+			if tc.chunked {
+				body = io.MultiReader(body) // oculta el largo a httptest
+			}
+			req := httptest.NewRequest(http.MethodPost, tc.path, body)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want == http.StatusRequestEntityTooLarge {
+				if code := errorCode(t, rec); code != ErrPayloadTooLarge.Code {
+					t.Errorf("code = %q, want %q", code, ErrPayloadTooLarge.Code)
+				}
+			}
+		})
+	}
+}
+
+// Una señal legacy chica que se infla al descomprimir también es 413
+func TestHandleSDPDecompressedTooLarge(t *testing.T) {
+	defer func(prev int64) { maxSignalBytes = prev }(maxSignalBytes)
+	maxSignalBytes = 1 << 10
+
+	zipped, err := signalZip(bytes.Repeat([]byte(" "), 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := signalEncode("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bomb := base64.StdEncoding.EncodeToString(zipped) + ";" + enc
+	if len(bomb) > 64<<10 {
+		t.Fatalf("el payload comprimido debería ser chico, mide %d", len(bomb))
+	}
+
+	h := withBodyLimit(http.HandlerFunc(handleSDP), MaxBodyBytes, MaxSDPBodyBytes)
+	req := httptest.NewRequest(http.MethodPost, "/sdp", strings.NewReader(bomb))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 (body %s)", rec.Code, rec.Body)
+	}
+	if code := errorCode(t, rec); code != ErrPayloadTooLarge.Code {
+		t.Errorf("code = %q, want %q", code, ErrPayloadTooLarge.Code)
+	}
+}
+
+func TestSignalUnzipLimit(t *testing.T) {
+	defer func(prev int64) { maxSignalBytes = prev }(maxSignalBytes)
+	maxSignalBytes = 8
+
+	for _, tc := range []struct {
+		in      string
+		wantErr error
+	}{
+		{"12345678", nil},
+		{"123456789", errSignalTooLarge},
+	} {
+		zipped, err := signalZip([]byte(tc.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := signalUnzip(zipped)
+		if err != tc.wantErr {
+			t.Errorf("signalUnzip(%q) err = %v, want %v", tc.in, err, tc.wantErr)
+		}
+		if err == nil && string(out) != tc.in {
+			t.Errorf("signalUnzip(%q) = %q", tc.in, out)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	called := false
+	h := withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	req := httptest.NewRequest(http.MethodOptions, "/sdp", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	if called {
+		t.Error("el preflight no debe llegar al handler")
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, " + OutOGGHeader,
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestCORSHeadersOnResponses(t *testing.T) {
+	t.Setenv("CORS_ALLOW_ORIGIN", "https://app.example")
+	h := withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, el request normal debe llegar al handler", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin con un origen fijo", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Call-ID" {
+		t.Errorf("Expose-Headers = %q", got)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Formatos de /sdp =========================
+
+// Body JSON de /sdp (Content-Type: application/json)
+type sdpRequest struct {
+	Offer       webrtc.SessionDescription `json:"offer"`
+	Candidates  []webrtc.ICECandidateInit `json:"candidates"`
+	OutOGGPath  string                    `json:"outOggPath,omitempty"`
+	IdleSeconds *int                      `json:"idleSeconds,omitempty"`
+}
+
+// Respuesta JSON de /sdp (solo si el request fue JSON)
+type sdpResponse struct {
+	Answer     webrtc.SessionDescription `json:"answer"`
+	Candidates []webrtc.ICECandidateInit `json:"candidates"`
+	CallID     string                    `json:"callId"`
+}
+
+// isJSONRequest indica si el cliente mandó el body como JSON
+func isJSONRequest(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
+
+// parseSDPRequest interpreta el body en JSON o en el formato legacy
+// "<offerEncoded>;<candidatesEncoded>"
+func parseSDPRequest(body []byte, asJSON bool) (*sdpRequest, error) {
+	if asJSON {
+		var req sdpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("JSON inválido: %w", err)
+		}
+		if req.Offer.SDP == "" {
+			return nil, errors.New("falta offer.sdp")
+		}
+		return &req, nil
+	}
+
+	payload := strings.TrimSpace(string(body))
+	parts := strings.Split(payload, ";")
+	if len(parts) != 2 {
+		return nil, errors.New("formato esperado: <offerEncoded>;<candidatesEncoded>")
+	}
+
+	req := &sdpRequest{}
+	if err := signalDecode(parts[0], &req.Offer); err != nil {
+		return nil, fmt.Errorf("offer inválida: %w", err)
+	}
+	if err := signalDecode(parts[1], &req.Candidates); err != nil {
+		return nil, fmt.Errorf("candidatos inválidos: %w", err)
+	}
+	return req, nil
+}
+
+// Respuesta JSON de /ice-restart: la oferta nueva del servidor
+type iceRestartResponse struct {
+	Offer      webrtc.SessionDescription `json:"offer"`
+	Candidates []webrtc.ICECandidateInit `json:"candidates"`
+	CallID     string                    `json:"callId"`
+	Next       int                       `json:"next"` // índice para GET /ice-candidate?since=
+}
+
+// parseRemoteAnswer lee la answer de /ice-restart: JSON {"answer": {...},
+// "candidates": [...]} o el formato legacy de /sdp "<answerEncoded>;<candidatesEncoded>"
+// (los candidatos son opcionales)
+func parseRemoteAnswer(body []byte, asJSON bool) (webrtc.SessionDescription, []webrtc.ICECandidateInit, error) {
+	var req struct {
+		Answer     webrtc.SessionDescription `json:"answer"`
+		Candidates []webrtc.ICECandidateInit `json:"candidates"`
+	}
+	if asJSON {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req.Answer, nil, fmt.Errorf("JSON inválido: %w", err)
+		}
+	} else {
+		parts := strings.Split(strings.TrimSpace(string(body)), ";")
+		if err := signalDecode(parts[0], &req.Answer); err != nil {
+			return req.Answer, nil, fmt.Errorf("answer inválida: %w", err)
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			if err := signalDecode(parts[1], &req.Candidates); err != nil {
+				return req.Answer, nil, fmt.Errorf("candidatos inválidos: %w", err)
+			}
+		}
+	}
+	if req.Answer.SDP == "" {
+		return req.Answer, nil, errors.New("falta answer.sdp")
+	}
+	if req.Answer.Type != webrtc.SDPTypeAnswer {
+		return req.Answer, nil, errors.New("se esperaba una answer")
+	}
+	return req.Answer, req.Candidates, nil
+}
+
+// writeSDPResponse responde en el mismo formato en que llegó el request
+func writeSDPResponse(w http.ResponseWriter, asJSON bool, callID string,
+	answer webrtc.SessionDescription, candidates []webrtc.ICECandidateInit) {
+	writeSignalResponse(w, asJSON, callID, sdpResponse{
+		Answer:     answer,
+		Candidates: candidates,
+		CallID:     callID,
+	}, answer, candidates)
+}
+
+// writeSignalResponse escribe jsonBody si el request fue JSON, o
+// "<descEncoded>;<candidatesEncoded>" en el formato legacy
+func writeSignalResponse(w http.ResponseWriter, asJSON bool, callID string, jsonBody any,
+	desc webrtc.SessionDescription, candidates []webrtc.ICECandidateInit) {
+
+	// Devolver el callID por header (para /hangup)
+	w.Header().Set("X-Call-ID", callID)
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jsonBody)
+		return
+	}
+
+	descEnc, err := signalEncode(desc)
+	if err != nil {
+		writeError(w, ErrInternal, "no se pudo codificar "+desc.Type.String()+": "+err.Error())
+		return
+	}
+	candidatesEnc, err := signalEncode(candidates)
+	if err != nil {
+		writeError(w, ErrInternal, "no se pudo codificar candidatos: "+err.Error())
+		return
+	}
+	out := descEnc + ";" + candidatesEnc
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(out))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const testSDP = "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"
+
+func testCandidates() []webrtc.ICECandidateInit {
+	mid := "0"
+	return []webrtc.ICECandidateInit{{Candidate: "candidate:1 1 udp 2130706431 10.0.0.1 5000 typ host", SDPMid: &mid}}
+}
+
+func TestIsJSONRequest(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"text/plain":                      false,
+		"":                                false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/sdp", nil)
+		r.Header.Set("Content-Type", ct)
+		if got := isJSONRequest(r); got != want {
+			t.Errorf("isJSONRequest(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestParseSDPRequestJSON(t *testing.T) {
+	body := `{"offer":{"type":"offer","sdp":"` + strings.ReplaceAll(testSDP, "\r\n", `\r\n`) + `"},` +
+		`"candidates":[{"candidate":"candidate:1 1 udp 1 10.0.0.1 5000 typ host","sdpMid":"0"}],` +
+		`"outOggPath":"hold.ogg","idleSeconds":30}`
+
+	req, err := parseSDPRequest([]byte(body), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Offer.Type != webrtc.SDPTypeOffer || req.Offer.SDP != testSDP {
+		t.Errorf("offer = %+v", req.Offer)
+	}
+	if len(req.Candidates) != 1 || *req.Candidates[0].SDPMid != "0" {
+		t.Errorf("candidates = %+v", req.Candidates)
+	}
+	if req.OutOGGPath != "hold.ogg" || req.IdleSeconds == nil || *req.IdleSeconds != 30 {
+		t.Errorf("outOggPath/idleSeconds = %q/%v", req.OutOGGPath, req.IdleSeconds)
+	}
+}
+
+func TestParseSDPRequestLegacy(t *testing.T) {
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: testSDP}
+	offerEnc, err := signalEncode(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candEnc, err := signalEncode(testCandidates())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := parseSDPRequest([]byte(" "+offerEnc+";"+candEnc+"\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Offer != offer {
+		t.Errorf("offer = %+v, want %+v", req.Offer, offer)
+	}
+	if len(req.Candidates) != 1 || req.Candidates[0].Candidate != testCandidates()[0].Candidate {
+		t.Errorf("candidates = %+v", req.Candidates)
+	}
+}
+
+func TestParseSDPRequestErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		asJSON bool
+	}{
+		{"JSON malformado", `{"offer":`, true},
+		{"JSON sin offer.sdp", `{"offer":{"type":"offer"}}`, true},
+		{"legacy sin separador", "abc", false},
+		{"legacy con tres partes", "a;b;c", false},
+	}
+	for _, tc := range cases {
+		if _, err := parseSDPRequest([]byte(tc.body), tc.asJSON); err == nil {
+			t.Errorf("%s: se esperaba error", tc.name)
+		}
+	}
+}
+
+// Los requests inválidos se rechazan con 400 antes de crear la llamada
+func TestHandleSDPBadRequest(t *testing.T) {
+	cases := []struct {
+		name, body, contentType string
+	}{
+		{"JSON malformado", `{"offer": {`, "application/json"},
+		{"JSON sin oferta", `{"candidates": []}`, "application/json"},
+		{"legacy malformado", "no-es-una-oferta", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postSDP(t, tc.body, tc.contentType)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			if code := errorCode(t, rec); code != ErrInvalidSDP.Code {
+				t.Errorf("code = %q, want %q", code, ErrInvalidSDP.Code)
+			}
+			if n := core.ActiveCalls(); n != 0 {
+				t.Errorf("quedaron %d lugares de llamada reservados", n)
+			}
+		})
+	}
+}
+
+// La respuesta sale en el mismo formato que el request
+func TestWriteSDPResponse(t *testing.T) {
+	answer := webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: testSDP}
+
+	t.Run("JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeSDPResponse(rec, true, "call-1", answer, testCandidates())
+
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if id := rec.Header().Get("X-Call-ID"); id != "call-1" {
+			t.Errorf("X-Call-ID = %q", id)
+		}
+		var resp sdpResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Answer != answer || resp.CallID != "call-1" || len(resp.Candidates) != 1 {
+			t.Errorf("respuesta = %+v", resp)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeSDPResponse(rec, false, "call-1", answer, testCandidates())
+
+		if id := rec.Header().Get("X-Call-ID"); id != "call-1" {
+			t.Errorf("X-Call-ID = %q", id)
+		}
+		parts := strings.Split(rec.Body.String(), ";")
+		if len(parts) != 2 {
+			t.Fatalf("body = %q, want <answer>;<candidates>", rec.Body)
+		}
+		var got webrtc.SessionDescription
+		if err := signalDecode(parts[0], &got); err != nil || got != answer {
+			t.Errorf("answer = %+v (%v)", got, err)
+		}
+		var cands []webrtc.ICECandidateInit
+		if err := signalDecode(parts[1], &cands); err != nil || len(cands) != 1 {
+			t.Errorf("candidates = %+v (%v)", cands, err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ========================= Rate limit por IP =========================
+
+// ipLimiter es un token bucket por IP de cliente. Los buckets que quedan
+// sin uso se borran periódicamente para no crecer sin límite.
+type ipLimiter struct {
+	rate      float64 // tokens por segundo
+	burst     float64 // capacidad máxima del bucket
+	proxyHops int     // proxies propios delante (X-Forwarded-For); 0 = RemoteAddr
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newIPLimiter(rate float64, burst int, proxyHops int) *ipLimiter {
+	return &ipLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		proxyHops: proxyHops,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// allow consume un token de ip; false si el bucket está vacío
+func (l *ipLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// cleanup borra los buckets sin uso desde hace más de idle
+func (l *ipLimiter) cleanup(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, b := range l.buckets {
+		if time.Since(b.last) > idle {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// janitor ejecuta cleanup cada interval (bloquea; lanzar en goroutine)
+func (l *ipLimiter) janitor(interval, idle time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		l.cleanup(idle)
+	}
+}
+
+// wrap rechaza con 429 los requests que exceden el límite de su IP
+func (l *ipLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.rate <= 0 {
+			next(w, r)
+			return
+		}
+		ip := clientIP(r, l.proxyHops)
+		if !l.allow(ip) {
+			logger.Warn("Rate limit excedido", "ip", ip, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(1/l.rate)+1))
+			writeError(w, ErrRateLimited, "")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP obtiene la IP del cliente. Con proxyHops > 0 la toma de
+// X-Forwarded-For contando proxyHops entradas desde la derecha: cada proxy
+// agrega al final la IP que vio, así que lo que está más a la izquierda lo
+// puede inventar el cliente.
+func clientIP(r *http.Request, proxyHops int) string {
+	if proxyHops > 0 {
+		var hops []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+		if len(hops) > 0 {
+			i := len(hops) - proxyHops
+			if i < 0 {
+				i = 0 // menos entradas que proxies: la más lejana que hay
+			}
+			if ip := strings.TrimSpace(hops[i]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
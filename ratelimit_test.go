@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name string
+		hops int
+		xff  []string
+		want string
+	}{
+		{"sin proxies ignora XFF", 0, []string{"1.1.1.1"}, "10.0.0.1"},
+		{"un proxy toma la última entrada", 1, []string{"6.6.6.6, 2.2.2.2"}, "2.2.2.2"},
+		{"dos proxies", 2, []string{"6.6.6.6, 2.2.2.2, 10.0.0.9"}, "2.2.2.2"},
+		{"varios headers se concatenan", 1, []string{"6.6.6.6", "2.2.2.2"}, "2.2.2.2"},
+		{"menos entradas que proxies", 3, []string{"2.2.2.2"}, "2.2.2.2"},
+		{"entrada inválida usa RemoteAddr", 1, []string{"1.1.1.1, basura"}, "10.0.0.1"},
+		{"sin XFF usa RemoteAddr", 1, nil, "10.0.0.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/sdp", nil)
+			r.RemoteAddr = "10.0.0.1:5000"
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := clientIP(r, tc.hops); got != tc.want {
+				t.Errorf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// Rotar la entrada izquierda de X-Forwarded-For no debe esquivar el límite
+func TestRateLimitIgnoresSpoofedXFF(t *testing.T) {
+	l := newIPLimiter(0.001, 2, 1)
+	h := l.wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	codes := make([]int, 3)
+	for i := range codes {
+		r := httptest.NewRequest(http.MethodPost, "/sdp", nil)
+		r.Header.Set("X-Forwarded-For", "6.6.6."+strconv.Itoa(i)+", 2.2.2.2")
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("status = %v, want [200 200 429]", codes)
+	}
+}
+
+func TestIPLimiterBurst(t *testing.T) {
+	l := newIPLimiter(1, 3, 0)
+	for i := 0; i < 3; i++ {
+		if !l.allow("a") {
+			t.Fatalf("request %d dentro de la ráfaga rechazado", i+1)
+		}
+	}
+	if l.allow("a") {
+		t.Fatal("request fuera de la ráfaga permitido")
+	}
+	if !l.allow("b") {
+		t.Fatal("el bucket de otra IP no debe verse afectado")
+	}
+
+	// Un segundo después se repone un token
+	l.buckets["a"].last = l.buckets["a"].last.Add(-time.Second)
+	if !l.allow("a") {
+		t.Fatal("no se repuso el token tras 1s")
+	}
+	if l.allow("a") {
+		t.Fatal("se repuso más de un token")
+	}
+}
+
+func TestRateLimit429(t *testing.T) {
+	l := newIPLimiter(0.5, 1, 0)
+	h := l.wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/sdp", nil)
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+	if rec := do(); rec.Code != http.StatusOK {
+		t.Fatalf("primer request: status %d", rec.Code)
+	}
+	rec := do()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("segundo request: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want 3", got)
+	}
+	if code := errorCode(t, rec); code != ErrRateLimited.Code {
+		t.Errorf("code = %q", code)
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	l := newIPLimiter(0, 0, 0)
+	h := l.wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodPost, "/sdp", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("con rate 0 no debe limitar (request %d: %d)", i+1, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ========================= Grabaciones =========================
+
+// Content-Type por extensión de las grabaciones que sirve /recordings
+var recordingTypes = map[string]string{
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".ivf":  "video/x-ivf",
+	".h264": "video/h264",
+	".pcap": "application/vnd.tcpdump.pcap",
+}
+
+// Nombre de grabación: audio-<sufijo>.ogg, video-<sufijo>.ivf,
+// rtp-<sufijo>.pcap, ...
+var recordingNameRe = regexp.MustCompile(`^(audio|video|rtp)-([0-9A-Za-z_-]+)\.[a-z0-9]+$`)
+
+// Los ids de llamada tienen la forma <unixnano>-<n> (ver newCallID); los
+// segmentos rotados agregan -NNN (ver oggSegments)
+var callIDRe = regexp.MustCompile(`^(\d+-\d+)(?:-\d{3})?$`)
+
+// RecordingInfo describe un archivo de grabación en GET /recordings
+type RecordingInfo struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"` // audio | video | rtp
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	CallID     string    `json:"call_id,omitempty"`
+}
+
+// isRecordingName indica si name es una grabación servible: sin
+// directorios, con prefijo y extensión conocidos
+func isRecordingName(name string) bool {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return false
+	}
+	if _, ok := recordingTypes[filepath.Ext(name)]; !ok {
+		return false
+	}
+	return recordingNameRe.MatchString(name)
+}
+
+// recordingCallID extrae el id de llamada del nombre, si lo tiene
+func recordingCallID(name string) string {
+	m := recordingNameRe.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	if id := callIDRe.FindStringSubmatch(m[2]); id != nil {
+		return id[1]
+	}
+	return ""
+}
+
+// listRecordings devuelve las grabaciones de dir, las más nuevas primero
+func listRecordings(dir string) ([]RecordingInfo, error) {
+	out := []RecordingInfo{}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return out, nil // todavía no se grabó nada
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isRecordingName(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, RecordingInfo{
+			Name:       e.Name(),
+			Kind:       strings.SplitN(e.Name(), "-", 2)[0],
+			Size:       fi.Size(),
+			ModifiedAt: fi.ModTime(),
+			CallID:     recordingCallID(e.Name()),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ModifiedAt.After(out[j].ModifiedAt) })
+	return out, nil
+}
+
+// handleRecordings lista las grabaciones (GET /recordings) o descarga una
+// (GET /recordings/<nombre>)
+func handleRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, ErrMethodNotAllowed, "solo GET")
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/recordings"), "/")
+	if name == "" {
+		list, err := listRecordings(core.RecordingDir())
+		if err != nil {
+			writeError(w, ErrInternal, "no se pudo leer el directorio de grabaciones")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"count":      len(list),
+			"recordings": list,
+		})
+		return
+	}
+
+	// Nada de "../" ni subdirectorios: solo nombres de grabación
+	if !isRecordingName(name) {
+		writeError(w, ErrInvalidRecording, "")
+		return
+	}
+	f, err := os.Open(filepath.Join(core.RecordingDir(), name))
+	if err != nil {
+		writeError(w, ErrRecordingNotFound, "")
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		writeError(w, ErrRecordingNotFound, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", recordingTypes[filepath.Ext(name)])
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsRecordingName(t *testing.T) {
+	valid := []string{
+		"audio-1712345678901234567-4242.ogg",
+		"audio-1712345678901234567-4242-002.ogg",
+		"audio-1712345678901234567-4242.wav",
+		"video-1712345678901234567-4242.ivf",
+		"rtp-1712345678901234567-4242.pcap",
+	}
+	invalid := []string{
+		"",
+		"../audio-1-1.ogg",
+		"..%2faudio-1-1.ogg",
+		"sub/audio-1-1.ogg",
+		"/etc/passwd",
+		".audio-1-1.ogg",
+		"audio-1-1.json", // sidecar
+		"audio-1-1.exe",
+		"notes-1-1.ogg",
+		"audio-..ogg",
+		"audio-1 1.ogg",
+	}
+	for _, n := range valid {
+		if !isRecordingName(n) {
+			t.Errorf("isRecordingName(%q) = false", n)
+		}
+	}
+	for _, n := range invalid {
+		if isRecordingName(n) {
+			t.Errorf("isRecordingName(%q) = true", n)
+		}
+	}
+}
+
+func TestRecordingCallID(t *testing.T) {
+	for name, want := range map[string]string{
+		"audio-1712345678901234567-4242.ogg":     "1712345678901234567-4242",
+		"audio-1712345678901234567-4242-003.ogg": "1712345678901234567-4242",
+		"rtp-1712345678901234567-7.pcap":         "1712345678901234567-7",
+		"audio-1755881306.ogg":                   "", // grabaciones viejas por timestamp
+	} {
+		if got := recordingCallID(name); got != want {
+			t.Errorf("recordingCallID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// withRecordingDir apunta RECORDING_DIR a un directorio temporal con files
+func withRecordingDir(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("RECORDING_DIR", dir)
+	withCallManager(t)
+	for i, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mod := time.Now().Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func getRecordings(path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handleRecordings(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHandleRecordingsList(t *testing.T) {
+	withRecordingDir(t,
+		"audio-100-1.ogg",
+		"audio-100-1.json", // sidecar: no se lista
+		"notas.txt",
+		"video-100-1.ivf",
+	)
+
+	rec := getRecordings("/recordings")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var resp struct {
+		Count      int             `json:"count"`
+		Recordings []RecordingInfo `json:"recordings"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 2 || len(resp.Recordings) != 2 {
+		t.Fatalf("recordings = %+v, want 2", resp.Recordings)
+	}
+	// las más nuevas primero
+	first, second := resp.Recordings[0], resp.Recordings[1]
+	if first.Name != "video-100-1.ivf" || first.Kind != "video" || first.CallID != "100-1" {
+		t.Errorf("primera = %+v", first)
+	}
+	if second.Name != "audio-100-1.ogg" || second.Size != int64(len("audio-100-1.ogg")) {
+		t.Errorf("segunda = %+v", second)
+	}
+}
+
+func TestHandleRecordingsMissingDir(t *testing.T) {
+	t.Setenv("RECORDING_DIR", filepath.Join(t.TempDir(), "no-existe"))
+	rec := getRecordings("/recordings")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 con lista vacía", rec.Code)
+	}
+}
+
+func TestHandleRecordingsDownload(t *testing.T) {
+	withRecordingDir(t, "audio-100-1.ogg")
+
+	rec := getRecordings("/recordings/audio-100-1.ogg")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "audio/ogg" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="audio-100-1.ogg"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if rec.Body.String() != "audio-100-1.ogg" {
+		t.Errorf("body = %q", rec.Body)
+	}
+
+	if rec := getRecordings("/recordings/audio-999-9.ogg"); rec.Code != http.StatusNotFound {
+		t.Errorf("inexistente: status = %d, want 404", rec.Code)
+	}
+}
+
+// Un archivo fuera del directorio no se puede descargar con "../"
+func TestHandleRecordingsRejectsTraversal(t *testing.T) {
+	dir := withRecordingDir(t)
+	secret := filepath.Join(filepath.Dir(dir), "audio-1-1.ogg")
+	if err := os.WriteFile(secret, []byte("secreto"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		"/recordings/../audio-1-1.ogg",
+		"/recordings/..\\audio-1-1.ogg",
+		"/recordings/sub/../../audio-1-1.ogg",
+		"/recordings/..",
+	} {
+		// Path sin limpiar, como si no pasara por el redirect del mux
+		req := httptest.NewRequest(http.MethodGet, "/recordings/", nil)
+		req.URL.Path = path
+		rec := httptest.NewRecorder()
+		handleRecordings(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, rec.Code)
+		}
+		if rec.Body.String() == "secreto" {
+			t.Errorf("%s: se sirvió un archivo fuera del directorio", path)
+		}
+	}
+}
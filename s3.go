@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"webrtc-audio-server/tasks"
+)
+
+// ========================= Subida a S3 =========================
+
+// s3Uploader sube cada grabación terminada (y su sidecar) a un bucket
+// S3-compatible (AWS, MinIO, R2, ...) con un PUT firmado con SigV4. Sin
+// bucket configurado no hace nada. Igual que los webhooks, cada subida
+// corre en segundo plano con reintentos y nunca afecta a la llamada.
+type s3Uploader struct {
+	endpoint    string // esquema y host, p.ej. https://s3.us-east-1.amazonaws.com
+	bucket      string
+	region      string
+	prefix      string
+	accessKey   string
+	secretKey   string
+	token       string // AWS_SESSION_TOKEN, opcional
+	deleteLocal bool
+	attempts    int
+	backoff     time.Duration
+	client      *http.Client
+
+	pending tasks.Group // subidas en curso (ver wait)
+}
+
+// Se arma al arrancar desde el entorno (ver newS3Uploader)
+var uploads = newS3Uploader()
+
+// objectKey arma la clave <prefijo><call_id>/<inicio UTC>-<archivo>
+func (u *s3Uploader) objectKey(callID string, startedAt time.Time, file string) string {
+	return u.prefix + callID + "/" + startedAt.UTC().Format("20060102T150405Z") + "-" + filepath.Base(file)
+}
+
+// upload sube en segundo plano los archivos de una grabación terminada (la
+// grabación y su sidecar, ver newCallManager). Si alguno falla no se sigue y
+// la copia local queda.
+func (u *s3Uploader) upload(log *slog.Logger, callID string, startedAt time.Time, files ...string) {
+	if u.bucket == "" {
+		return
+	}
+	ok := u.pending.Go(func() {
+		for _, path := range files {
+			key := u.objectKey(callID, startedAt, path)
+			if err := u.putWithRetry(path, key); err != nil {
+				log.Warn("S3: subida fallida, queda la copia local", "path", path, "key", key, "err", err)
+				return
+			}
+			log.Info("S3: grabación subida", "path", path, "bucket", u.bucket, "key", key)
+		}
+		if u.deleteLocal {
+			for _, path := range files {
+				if err := os.Remove(path); err != nil {
+					log.Warn("S3: no se pudo borrar la copia local", "path", path, "err", err)
+				}
+			}
+		}
+	})
+	if !ok {
+		log.Warn("S3: subida descartada, el servidor se está apagando; queda la copia local", "files", files)
+	}
+}
+
+// wait deja de aceptar subidas nuevas y espera las que están en curso o a
+// que venza ctx
+func (u *s3Uploader) wait(ctx context.Context) error { return u.pending.Wait(ctx) }
+
+// putWithRetry reintenta con backoff exponencial ante errores de red o 5xx/429
+func (u *s3Uploader) putWithRetry(path, key string) error {
+	wait := u.backoff
+	var err error
+	for attempt := 1; attempt <= u.attempts; attempt++ {
+		var retry bool
+		if retry, err = u.put(path, key); err == nil {
+			return nil
+		}
+		if !retry || attempt == u.attempts {
+			break
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+	return err
+}
+
+// put hace un intento de PUT del archivo path en key (URL path-style:
+// <endpoint>/<bucket>/<key>); retry indica si vale la pena reintentar
+func (u *s3Uploader) put(path, key string) (retry bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// El hash del payload va firmado, así que se lee el archivo dos veces
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	target, err := url.Parse(u.endpoint)
+	if err != nil {
+		return false, err
+	}
+	target.Path = "/" + u.bucket + "/" + key
+	target.RawPath = s3EscapePath(target.Path)
+
+	req, err := http.NewRequest(http.MethodPut, target.String(), io.NopCloser(f))
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = size
+	if ct := recordingTypes[filepath.Ext(path)]; ct != "" {
+		req.Header.Set("Content-Type", ct)
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if u.token != "" {
+		req.Header.Set("X-Amz-Security-Token", u.token)
+	}
+	signV4(req, hex.EncodeToString(h.Sum(nil)), u.accessKey, u.secretKey, u.region, time.Now())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// signV4 firma req para S3 con AWS Signature Version 4. Firma host y todos
+// los headers presentes en req.Header, que tienen que estar puestos antes.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	const service = "s3"
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Headers canónicos: nombre en minúsculas, valor sin espacios de más
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(strings.Fields(strings.Join(values, ",")), " ")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, name := range names {
+		canonHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath codifica cada segmento como pide SigV4: todo salvo
+// A-Z a-z 0-9 - _ . ~ va como %XX
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
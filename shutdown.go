@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// ========================= Apagado ordenado =========================
+
+// En true el servidor ya no acepta llamadas nuevas (ver handleSDP)
+var draining atomic.Bool
+
+// shutdown apaga el servidor HTTP con Shutdown (deja terminar los requests
+// en curso, p.ej. un /sdp esperando el gathering) y después cuelga las
+// llamadas, incluidas las que esos requests hayan creado
+func shutdown(ctx context.Context, srv *http.Server) {
+	draining.Store(true) // /sdp responde 503 mientras tanto
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn("Shutdown HTTP incompleto", "err", err)
+	}
+	drainCalls(ctx)
+}
+
+// drainCalls deja de aceptar llamadas nuevas, cuelga las activas y espera
+// a que sus PeerConnection terminen de cerrarse, a que se cierren las
+// grabaciones, a que se suban y a que salgan los webhooks, o a que venza
+// ctx. Cada paso genera trabajo para los siguientes (una grabación cerrada
+// se sube, colgar emite call_ended), así que se esperan en ese orden y cada
+// uno deja de aceptar trabajo nuevo recién cuando le toca.
+func drainCalls(ctx context.Context) {
+	draining.Store(true)
+	logger.Info("Shutdown: colgando llamadas activas", "count", len(core.Calls()))
+
+	err := core.Shutdown(ctx)
+	if err == nil {
+		err = uploads.wait(ctx)
+	}
+	if err == nil {
+		err = webhooks.wait(ctx)
+	}
+	if err != nil {
+		logger.Warn("Shutdown: venció el tiempo de gracia, saliendo igual", "err", err)
+		return
+	}
+	logger.Info("Shutdown: llamadas cerradas, grabaciones y webhooks terminados")
+}
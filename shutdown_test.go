@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"webrtc-audio-server/audiocore"
+)
+
+// withDrainState da al test su propio core y uploader para que drainCalls
+// no deje cerrados los globales
+func withDrainState(t *testing.T) {
+	t.Helper()
+	withCallManager(t)
+	prev := uploads
+	uploads = &s3Uploader{}
+	t.Cleanup(func() {
+		uploads = prev
+		draining.Store(false)
+	})
+}
+
+// drainCalls cuelga las llamadas y no vuelve hasta entregar sus webhooks
+func TestDrainCallsWaitsForWebhooks(t *testing.T) {
+	withDrainState(t)
+	rec := withTestWebhooks(t)
+	rec.block = make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(rec.block) })
+
+	call := newTestCall(t)
+	call.MarkStarted(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	drainCalls(ctx)
+
+	if !draining.Load() {
+		t.Error("drainCalls debería dejar draining en true")
+	}
+	if n := core.ActiveCalls(); n != 0 {
+		t.Errorf("ActiveCalls() = %d, want 0", n)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var ended bool
+	for _, ev := range rec.events {
+		ended = ended || ev.Event == audiocore.EventCallEnded
+	}
+	if !ended {
+		t.Fatalf("drainCalls volvió antes de entregar call_ended (eventos: %v)", rec.events)
+	}
+}
+
+// Con el webhook de una llamada trabado drainCalls sale al vencer la gracia
+func TestDrainCallsGraceTimeout(t *testing.T) {
+	withDrainState(t)
+	rec := withTestWebhooks(t)
+	rec.block = make(chan struct{})
+	t.Cleanup(func() { close(rec.block) }) // antes de cerrar el servidor de prueba
+
+	call := newTestCall(t)
+	call.MarkStarted(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	drainCalls(ctx)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("drainCalls tardó %v con la gracia vencida", d)
+	}
+	select {
+	case <-call.Done:
+	default:
+		t.Error("la llamada sigue abierta")
+	}
+}
+
+// Un /sdp en curso al empezar el apagado termina con su answer; los nuevos
+// reciben 503 y la llamada que creó se cuelga después
+func TestShutdownFinishesInFlightSDP(t *testing.T) {
+	withDrainState(t)
+	withTestWebhooks(t)
+	_, offer := newClientOffer(t)
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		handleSDP(w, r)
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+
+	// El body llega en dos partes: handleSDP queda leyéndolo durante el apagado
+	pr, pw := io.Pipe()
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Post("http://"+ln.Addr().String()+"/sdp", "application/json", pr)
+		inFlight <- result{resp, err}
+	}()
+	half := len(offer) / 2
+	if _, err := pw.Write([]byte(offer[:half])); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		shutdown(ctx, srv)
+		close(stopped)
+	}()
+	// Si el test corta antes, shutdown no debe seguir con el core del test
+	t.Cleanup(func() {
+		_ = pw.Close()
+		cancel()
+		<-stopped
+	})
+	for !draining.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := postSDP(t, offer, "application/json")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/sdp nuevo: status = %d, want 503", rec.Code)
+	}
+	if code := errorCode(t, rec); code != ErrServerDraining.Code {
+		t.Errorf("/sdp nuevo: code = %q, want %q", code, ErrServerDraining.Code)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("shutdown no esperó al /sdp en curso")
+	default:
+	}
+
+	if _, err := pw.Write([]byte(offer[half:])); err != nil {
+		t.Fatal(err)
+	}
+	_ = pw.Close()
+	res := <-inFlight
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	defer res.resp.Body.Close()
+	if res.resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.resp.Body)
+		t.Fatalf("/sdp en curso: status = %d: %s", res.resp.StatusCode, body)
+	}
+	var answer sdpResponse
+	if err := json.NewDecoder(res.resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if answer.CallID == "" || answer.Answer.SDP == "" {
+		t.Errorf("respuesta incompleta: %+v", answer)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("shutdown no terminó")
+	}
+	if n := core.ActiveCalls(); n != 0 {
+		t.Errorf("ActiveCalls() = %d, want 0: la llamada del /sdp en curso sigue abierta", n)
+	}
+}
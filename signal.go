@@ -5,62 +5,71 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
 )
 
 const compress = true
 
-func signalEncode(obj any) string {
+// Tope del JSON descomprimido de una señal legacy. El body comprimido ya lo
+// corta withBodyLimit, pero gzip puede inflarse miles de veces. main lo
+// iguala a MAX_SDP_BODY_BYTES.
+var maxSignalBytes int64 = MaxSDPBodyBytes
+
+var errSignalTooLarge = errors.New("señal descomprimida demasiado grande")
+
+func signalEncode(obj any) (string, error) {
 	b, err := json.Marshal(obj)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	if compress {
-		b = signalZip(b)
+		if b, err = signalZip(b); err != nil {
+			return "", err
+		}
 	}
-	return base64.StdEncoding.EncodeToString(b)
+	return base64.StdEncoding.EncodeToString(b), nil
 }
 
-func signalDecode(in string, obj any) {
+func signalDecode(in string, obj any) error {
 	b, err := base64.StdEncoding.DecodeString(in)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if compress {
-		b = signalUnzip(b)
-	}
-	if err := json.Unmarshal(b, obj); err != nil {
-		panic(err)
+		if b, err = signalUnzip(b); err != nil {
+			return err
+		}
 	}
+	return json.Unmarshal(b, obj)
 }
 
-func signalZip(in []byte) []byte {
+func signalZip(in []byte) ([]byte, error) {
 	var b bytes.Buffer
 	gz := gzip.NewWriter(&b)
 	if _, err := gz.Write(in); err != nil {
-		panic(err)
+		return nil, err
 	}
 	if err := gz.Flush(); err != nil {
-		panic(err)
+		return nil, err
 	}
 	if err := gz.Close(); err != nil {
-		panic(err)
+		return nil, err
 	}
-	return b.Bytes()
+	return b.Bytes(), nil
 }
 
-func signalUnzip(in []byte) []byte {
-	var b bytes.Buffer
-	if _, err := b.Write(in); err != nil {
-		panic(err)
-	}
-	r, err := gzip.NewReader(&b)
+func signalUnzip(in []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(in))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	res, err := io.ReadAll(r)
+	b, err := io.ReadAll(io.LimitReader(r, maxSignalBytes+1))
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if int64(len(b)) > maxSignalBytes {
+		return nil, errSignalTooLarge
 	}
-	return res
+	return b, nil
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestSignalRoundTrip(t *testing.T) {
+	in := map[string]any{"type": "offer", "sdp": testSDP}
+	enc, err := signalEncode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := signalDecode(enc, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["type"] != "offer" || out["sdp"] != testSDP {
+		t.Errorf("decodificado = %v", out)
+	}
+}
+
+func TestSignalDecodeErrors(t *testing.T) {
+	notGzip := base64.StdEncoding.EncodeToString([]byte("no es gzip"))
+	zipped, err := signalZip([]byte("{no es json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badJSON := base64.StdEncoding.EncodeToString(zipped)
+
+	for name, in := range map[string]string{
+		"base64 inválido": "%%%no-base64%%%",
+		"no es gzip":      notGzip,
+		"JSON inválido":   badJSON,
+		"vacío":           "",
+	} {
+		var out map[string]any
+		if err := signalDecode(in, &out); err == nil {
+			t.Errorf("%s: se esperaba error", name)
+		}
+	}
+}
+
+// Un payload legacy con base64 inválido es un 400 limpio, no un panic ni 500
+func TestHandleSDPInvalidBase64(t *testing.T) {
+	for _, body := range []string{"%%%;%%%", "!!!!;" + base64.StdEncoding.EncodeToString([]byte("x"))} {
+		rec := postSDP(t, body, "text/plain")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: status = %d, want 400", body, rec.Code)
+		}
+		if code := errorCode(t, rec); code != ErrInvalidSDP.Code {
+			t.Errorf("%q: code = %q, want %q", body, code, ErrInvalidSDP.Code)
+		}
+	}
+}
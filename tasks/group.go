@@ -0,0 +1,76 @@
+// Package tasks sigue el trabajo en segundo plano que el apagado tiene que
+// esperar (grabaciones cerrándose, webhooks, subidas). A diferencia de un
+// sync.WaitGroup, un Group deja de admitir tareas al esperar y la espera
+// respeta un contexto sin dejar goroutines colgadas.
+package tasks
+
+import (
+	"context"
+	"sync"
+)
+
+// Group es un conjunto de goroutines. El valor cero está listo para usar;
+// cada dueño (Manager, notificador, uploader) tiene el suyo.
+type Group struct {
+	mu      sync.Mutex
+	running int
+	closed  bool
+	idle    chan struct{} // se cierra con closed y running == 0
+}
+
+// Go corre fn en una goroutine del grupo. Devuelve false sin correrla si el
+// grupo ya está esperando (ver Wait).
+func (g *Group) Go(fn func()) bool {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return false
+	}
+	g.running++
+	g.mu.Unlock()
+
+	go func() {
+		defer g.done()
+		fn()
+	}()
+	return true
+}
+
+func (g *Group) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running--
+	if g.closed && g.running == 0 {
+		close(g.idleChan())
+	}
+}
+
+// Wait deja de admitir tareas nuevas y espera a que terminen las que están
+// corriendo o a que venza ctx (devuelve ctx.Err()). Se puede llamar más de
+// una vez.
+func (g *Group) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	idle := g.idleChan()
+	if !g.closed {
+		g.closed = true
+		if g.running == 0 {
+			close(idle)
+		}
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// idleChan crea idle la primera vez; se llama con mu tomado
+func (g *Group) idleChan() chan struct{} {
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+	}
+	return g.idle
+}
@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupWaitsForRunning(t *testing.T) {
+	var g Group
+	var finished atomic.Bool
+	g.Go(func() {
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Load() {
+		t.Fatal("Wait volvió antes de que terminara la tarea")
+	}
+}
+
+// Ya esperando, el grupo no admite tareas nuevas
+func TestGroupRejectsAfterWait(t *testing.T) {
+	var g Group
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if g.Go(func() { t.Error("corrió una tarea admitida después de Wait") }) {
+		t.Fatal("Go después de Wait devolvió true")
+	}
+	// una segunda espera vuelve enseguida
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Con una tarea trabada Wait vuelve al vencer ctx
+func TestGroupWaitDeadline(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	defer close(release)
+	g.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := g.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Wait = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Wait tardó %v con ctx vencido", d)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ========================= Transporte (SettingEngine) =========================
+
+// transportConfig es la configuración de red/DTLS común a todas las llamadas.
+// Se lee y valida una vez al arrancar (ver loadTransportConfig).
+type transportConfig struct {
+	dtlsRole     webrtc.DTLSRole // rol DTLS al responder (ver answeringRole)
+	networkTypes []webrtc.NetworkType
+	portMin      uint16 // 0 = rango efímero del sistema
+	portMax      uint16
+}
+
+// Redes ICE si no se define NETWORK_TYPES (dual stack)
+var defaultNetworkTypes = []webrtc.NetworkType{webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6}
+
+// Nombres aceptados en NETWORK_TYPES
+var networkTypeNames = map[string]webrtc.NetworkType{
+	"udp4": webrtc.NetworkTypeUDP4,
+	"udp6": webrtc.NetworkTypeUDP6,
+	"tcp4": webrtc.NetworkTypeTCP4,
+	"tcp6": webrtc.NetworkTypeTCP6,
+}
+
+// Configuración en uso. Arranca con los defaults para que core funcione antes
+// de que main lea el entorno (p.ej. en tests); main la reemplaza con
+// loadTransportConfig.
+var transport = transportConfig{dtlsRole: webrtc.DTLSRoleClient, networkTypes: defaultNetworkTypes}
+
+// loadTransportConfig lee del entorno:
+//   - DTLS_ROLE: auto | client | server (default client, a=setup:active)
+//   - NETWORK_TYPES: redes ICE separadas por comas, p.ej. "udp4,udp6,tcp4"
+//     (default udp4,udp6)
+//   - UDP_PORT_MIN / UDP_PORT_MAX: rango UDP para firewalls estrictos, entre
+//     1024 y 65535 y min <= max. Hay que definir los dos o ninguno.
+func loadTransportConfig() (transportConfig, error) {
+	var tc transportConfig
+	var err error
+	if tc.dtlsRole, err = parseDTLSRole(os.Getenv("DTLS_ROLE")); err != nil {
+		return tc, err
+	}
+	if tc.networkTypes, err = parseNetworkTypes(os.Getenv("NETWORK_TYPES")); err != nil {
+		return tc, err
+	}
+	if tc.portMin, tc.portMax, err = parsePortRange(os.Getenv("UDP_PORT_MIN"), os.Getenv("UDP_PORT_MAX")); err != nil {
+		return tc, err
+	}
+	return tc, nil
+}
+
+// parsePortRange valida UDP_PORT_MIN/UDP_PORT_MAX; sin ninguno devuelve 0, 0
+func parsePortRange(rawMin, rawMax string) (uint16, uint16, error) {
+	if rawMin == "" && rawMax == "" {
+		return 0, 0, nil
+	}
+	if rawMin == "" || rawMax == "" {
+		return 0, 0, errors.New("UDP_PORT_MIN y UDP_PORT_MAX van juntos")
+	}
+	lo, err := strconv.Atoi(rawMin)
+	if err != nil {
+		return 0, 0, fmt.Errorf("UDP_PORT_MIN inválido: %q", rawMin)
+	}
+	hi, err := strconv.Atoi(rawMax)
+	if err != nil {
+		return 0, 0, fmt.Errorf("UDP_PORT_MAX inválido: %q", rawMax)
+	}
+	if lo < 1024 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("rango UDP inválido %d-%d (1024-65535, min <= max)", lo, hi)
+	}
+	return uint16(lo), uint16(hi), nil
+}
+
+func parseNetworkTypes(raw string) ([]webrtc.NetworkType, error) {
+	names := splitList(strings.ToLower(raw))
+	if len(names) == 0 {
+		return defaultNetworkTypes, nil
+	}
+	var out []webrtc.NetworkType
+	for _, name := range names {
+		nt, ok := networkTypeNames[name]
+		if !ok {
+			return nil, fmt.Errorf("NETWORK_TYPES: red desconocida %q (udp4, udp6, tcp4, tcp6)", name)
+		}
+		out = append(out, nt)
+	}
+	return out, nil
+}
+
+func parseDTLSRole(raw string) (webrtc.DTLSRole, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "client":
+		return webrtc.DTLSRoleClient, nil
+	case "server":
+		return webrtc.DTLSRoleServer, nil
+	case "auto":
+		return webrtc.DTLSRoleAuto, nil
+	}
+	return 0, fmt.Errorf("DTLS_ROLE inválido: %q (auto, client o server)", raw)
+}
+
+// answeringRole es el rol DTLS con el que se responde: DTLSRoleAuto = lo que
+// decida pion; sin rol (la config vacía) vale el default, client
+func (tc transportConfig) answeringRole() webrtc.DTLSRole {
+	if tc.dtlsRole == 0 {
+		return webrtc.DTLSRoleClient
+	}
+	return tc.dtlsRole
+}
+
+// settingEngine arma el SettingEngine de una llamada
+func (tc transportConfig) settingEngine() (webrtc.SettingEngine, error) {
+	se := webrtc.SettingEngine{}
+	se.SetNetworkTypes(tc.networkTypes)
+	if tc.portMin != 0 {
+		if err := se.SetEphemeralUDPPortRange(tc.portMin, tc.portMax); err != nil {
+			return se, err
+		}
+	}
+	// auto: pion responde a=setup:active salvo que la oferta lo impida
+	if role := tc.answeringRole(); role != webrtc.DTLSRoleAuto {
+		if err := se.SetAnsweringDTLSRole(role); err != nil {
+			return se, err
+		}
+	}
+	return se, nil
+}
+
+// String resume la configuración para el log de arranque
+func (tc transportConfig) String() string {
+	role := "auto"
+	switch tc.answeringRole() {
+	case webrtc.DTLSRoleClient:
+		role = "client"
+	case webrtc.DTLSRoleServer:
+		role = "server"
+	}
+	var nets []string
+	for _, nt := range tc.networkTypes {
+		nets = append(nets, nt.String())
+	}
+	out := "dtls_role=" + role + " network_types=" + strings.Join(nets, ",")
+	if tc.portMin != 0 {
+		out += fmt.Sprintf(" udp_ports=%d-%d", tc.portMin, tc.portMax)
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"webrtc-audio-server/audiocore"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// negotiateAnswer arma la answer que daría el servidor con tc y profile a
+// una oferta de audio sendrecv, con un Manager como el de handleSDP
+func negotiateAnswer(t *testing.T, tc transportConfig, profile audiocore.MediaProfile) string {
+	t.Helper()
+
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = offerer.Close() })
+	if _, err := offerer.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := audiocore.NewManager(audiocore.Config{
+		Logger:        logger,
+		Media:         profile,
+		SettingEngine: tc.settingEngine,
+		RecordingDir:  t.TempDir(),
+	})
+	call, err := m.CreateCall(audiocore.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.CloseCall(call) })
+
+	answer, err := call.Answer(offer, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return answer.SDP
+}
+
+// Cada DTLS_ROLE se refleja en el a=setup: de la answer
+func TestDTLSRoleSetupLine(t *testing.T) {
+	cases := []struct {
+		role string
+		want string
+	}{
+		{"", "a=setup:active"}, // default: client, como antes
+		{"client", "a=setup:active"},
+		{"server", "a=setup:passive"},
+		{"auto", "a=setup:active"}, // lo que elige pion ante actpass
+	}
+	for _, tc := range cases {
+		t.Run("DTLS_ROLE="+tc.role, func(t *testing.T) {
+			t.Setenv("DTLS_ROLE", tc.role)
+			cfg, err := loadTransportConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sdp := negotiateAnswer(t, cfg, audiocore.MediaProfile{Name: audiocore.MediaProfileDefault})
+			if !strings.Contains(sdp, tc.want+"\r\n") {
+				t.Errorf("answer sin %q:\n%s", tc.want, sdp)
+			}
+		})
+	}
+}
+
+// La config vacía (transport antes de que main lea el entorno) responde
+// como client en vez de fallar en SetAnsweringDTLSRole
+func TestZeroTransportConfig(t *testing.T) {
+	var tc transportConfig
+	if _, err := tc.settingEngine(); err != nil {
+		t.Fatalf("settingEngine(): %v", err)
+	}
+	if got := tc.answeringRole(); got != webrtc.DTLSRoleClient {
+		t.Errorf("answeringRole() = %v, want client", got)
+	}
+	if !strings.HasPrefix(tc.String(), "dtls_role=client ") {
+		t.Errorf("String() = %q", tc.String())
+	}
+}
+
+func TestParseDTLSRole(t *testing.T) {
+	for raw, want := range map[string]webrtc.DTLSRole{
+		"":        webrtc.DTLSRoleClient,
+		"client":  webrtc.DTLSRoleClient,
+		" Server": webrtc.DTLSRoleServer,
+		"auto":    webrtc.DTLSRoleAuto,
+	} {
+		got, err := parseDTLSRole(raw)
+		if err != nil || got != want {
+			t.Errorf("parseDTLSRole(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	if _, err := parseDTLSRole("passive"); err == nil {
+		t.Error("parseDTLSRole(passive) debería fallar")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	valid := []struct {
+		min, max string
+		lo, hi   uint16
+	}{
+		{"", "", 0, 0}, // sin definir: rango del sistema
+		{"40000", "40100", 40000, 40100},
+		{"1024", "65535", 1024, 65535},
+		{"50000", "50000", 50000, 50000},
+	}
+	for _, tc := range valid {
+		lo, hi, err := parsePortRange(tc.min, tc.max)
+		if err != nil || lo != tc.lo || hi != tc.hi {
+			t.Errorf("parsePortRange(%q, %q) = %d, %d, %v; want %d, %d", tc.min, tc.max, lo, hi, err, tc.lo, tc.hi)
+		}
+	}
+
+	invalid := [][2]string{
+		{"40000", ""},
+		{"", "40100"},
+		{"40100", "40000"}, // min > max
+		{"80", "90"},       // puertos privilegiados
+		{"60000", "70000"}, // fuera de rango
+		{"abc", "40100"},
+		{"40000", "4e4"},
+	}
+	for _, tc := range invalid {
+		if _, _, err := parsePortRange(tc[0], tc[1]); err == nil {
+			t.Errorf("parsePortRange(%q, %q) debería fallar", tc[0], tc[1])
+		}
+	}
+}
+
+// Un rango inválido corta el arranque; uno válido llega al SettingEngine
+func TestLoadTransportConfigPortRange(t *testing.T) {
+	t.Setenv("UDP_PORT_MIN", "40100")
+	t.Setenv("UDP_PORT_MAX", "40000")
+	if _, err := loadTransportConfig(); err == nil {
+		t.Fatal("loadTransportConfig con min > max debería fallar")
+	}
+
+	t.Setenv("UDP_PORT_MIN", "40000")
+	t.Setenv("UDP_PORT_MAX", "40100")
+	tc, err := loadTransportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.portMin != 40000 || tc.portMax != 40100 {
+		t.Fatalf("rango = %d-%d", tc.portMin, tc.portMax)
+	}
+	if !strings.Contains(tc.String(), "udp_ports=40000-40100") {
+		t.Errorf("String() = %q", tc.String())
+	}
+	if _, err := tc.settingEngine(); err != nil {
+		t.Errorf("settingEngine con rango válido: %v", err)
+	}
+}
+
+// Las redes y el rango de puertos llegan al SettingEngine: los candidatos
+// host salen solo por UDP4 y dentro del rango
+func TestSettingEngineRestrictsCandidates(t *testing.T) {
+	tc := transportConfig{
+		dtlsRole:     webrtc.DTLSRoleClient,
+		networkTypes: []webrtc.NetworkType{webrtc.NetworkTypeUDP4},
+		portMin:      40000,
+		portMax:      40100,
+	}
+	se, err := tc.settingEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := webrtc.NewAPI(webrtc.WithSettingEngine(se)).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+
+	var candidates []string
+	for _, line := range strings.Split(pc.LocalDescription().SDP, "\r\n") {
+		if strings.HasPrefix(line, "a=candidate:") {
+			candidates = append(candidates, line)
+		}
+	}
+	if len(candidates) == 0 {
+		t.Skip("sin interfaces de red para juntar candidatos")
+	}
+	for _, c := range candidates {
+		// a=candidate:<foundation> <component> <transport> <priority> <ip> <port> typ ...
+		f := strings.Fields(c)
+		if len(f) < 6 {
+			t.Fatalf("candidato mal formado: %q", c)
+		}
+		if !strings.EqualFold(f[2], "udp") || strings.Contains(f[4], ":") {
+			t.Errorf("candidato fuera de udp4: %q", c)
+		}
+		if port, err := strconv.Atoi(f[5]); err != nil || port < 40000 || port > 40100 {
+			t.Errorf("puerto fuera de 40000-40100: %q", c)
+		}
+	}
+}
+
+func TestParseNetworkTypes(t *testing.T) {
+	got, err := parseNetworkTypes("")
+	if err != nil || len(got) != 2 || got[0] != webrtc.NetworkTypeUDP4 || got[1] != webrtc.NetworkTypeUDP6 {
+		t.Errorf("default = %v, %v; want udp4,udp6", got, err)
+	}
+	got, err = parseNetworkTypes(" UDP4, tcp4 ")
+	if err != nil || len(got) != 2 || got[0] != webrtc.NetworkTypeUDP4 || got[1] != webrtc.NetworkTypeTCP4 {
+		t.Errorf("udp4,tcp4 = %v, %v", got, err)
+	}
+	if _, err := parseNetworkTypes("udp4,sctp"); err == nil {
+		t.Error("una red desconocida debería fallar")
+	}
+}
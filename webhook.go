@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webrtc-audio-server/tasks"
+)
+
+// ========================= Webhooks =========================
+
+// Header con la firma HMAC-SHA256 del body: "sha256=<hex>"
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// webhookEvent es el JSON que se postea a cada URL por cada evento de
+// ciclo de vida (audiocore.EventCallStarted, ...)
+type webhookEvent struct {
+	Event     string    `json:"event"`
+	CallID    string    `json:"call_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// webhookNotifier entrega eventos a las URLs configuradas. Cada entrega corre
+// en su propia goroutine, así que un endpoint lento nunca frena la llamada;
+// el apagado las espera (ver wait).
+type webhookNotifier struct {
+	urls     []string
+	secret   []byte
+	attempts int
+	backoff  time.Duration
+	client   *http.Client
+
+	pending tasks.Group // entregas en curso
+}
+
+// Se arma al arrancar desde el entorno (ver newWebhookNotifier)
+var webhooks = newWebhookNotifier()
+
+// notify postea el evento a todas las URLs; sin URLs no hace nada
+func (n *webhookNotifier) notify(event, callID string, data any) {
+	if len(n.urls) == 0 {
+		return
+	}
+	body, err := json.Marshal(webhookEvent{
+		Event:     event,
+		CallID:    callID,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		logger.Error("Webhook: no se pudo serializar el evento", "event", event, "err", err)
+		return
+	}
+	for _, url := range n.urls {
+		if !n.pending.Go(func() { n.deliver(url, event, callID, body) }) {
+			logger.Warn("Webhook descartado: el servidor se está apagando", "url", url, "event", event, "call_id", callID)
+		}
+	}
+}
+
+// wait deja de aceptar entregas nuevas y espera las que están en curso o a
+// que venza ctx
+func (n *webhookNotifier) wait(ctx context.Context) error { return n.pending.Wait(ctx) }
+
+// deliver reintenta con backoff exponencial ante errores de red o 5xx/429
+func (n *webhookNotifier) deliver(url, event, callID string, body []byte) {
+	wait := n.backoff
+	var err error
+	for attempt := 1; attempt <= n.attempts; attempt++ {
+		var retry bool
+		if retry, err = n.post(url, body); err == nil {
+			return
+		}
+		if !retry || attempt == n.attempts {
+			break
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+	logger.Warn("Webhook: entrega fallida", "url", url, "event", event, "call_id", callID, "err", err)
+}
+
+// post hace un intento; retry indica si vale la pena reintentar
+func (n *webhookNotifier) post(url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("status %d", resp.StatusCode)
+}